@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	nethttp "net/http"
+	"os"
+	"time"
 
 	"goproduct/internals/adapter/http"
+	"goproduct/internals/adapter/publisher"
+	"goproduct/internals/adapter/repository/cache_repository"
+	"goproduct/internals/adapter/repository/memory_repository"
 	"goproduct/internals/adapter/repository/mongodb_repository"
 	"goproduct/internals/adapter/repository/mysql_repository"
+	"goproduct/internals/adapter/repository/postgres_repository"
+	"goproduct/internals/adapter/repository/shadow_repository"
+	"goproduct/internals/adapter/repository/sqlite_repository"
 	"goproduct/internals/config"
 	"goproduct/internals/core/product/application"
 	"goproduct/internals/core/product/port"
@@ -21,41 +31,129 @@ func main() {
 		log.Fatal("Error loading configuration:", err)
 	}
 
-	// Create the product repository
-	var productRepository port.ProductRepository
-	switch cfg.Database.Type {
-	case "mysql":
-		productRepository, err = mysql_repository.NewProductRepository(cfg.Database.MySQL.DSN)
-	case "mongodb":
-		productRepository, err = mongodb_repository.NewProductRepository(
-			cfg.Database.MongoDB.URI,
-			cfg.Database.MongoDB.Database,
-			cfg.Database.MongoDB.Collection,
-		)
-	default:
-		log.Fatalf("Unsupported database type: %s", cfg.Database.Type)
+	// Run a maintenance command instead of the server when one is given
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			err = runMigrate(cfg, os.Args[2:])
+		case "transfer":
+			err = runTransfer(cfg, os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command: %s", os.Args[1])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
+
+	// Create the product repository
+	productRepository, err := newProductRepository(cfg, cfg.Database.Type)
 	if err != nil {
 		log.Fatal("Error creating product repository:", err)
 	}
 
+	// Record product events and relay them downstream
+	if cfg.Outbox.Enabled {
+		relay, err := newOutboxRelay(cfg, productRepository)
+		if err != nil {
+			log.Fatal("Error creating outbox relay:", err)
+		}
+		go relay.Run(context.Background())
+	}
+
+	// Record changes next to the products in the same backend
+	auditRepository, err := newAuditRepository(cfg, productRepository)
+	if err != nil {
+		log.Fatal("Error creating audit repository:", err)
+	}
+
+	// Hold stock for reservations and release the holds that expire
+	reservationRepository, err := newReservationRepository(cfg, productRepository)
+	if err != nil {
+		log.Fatal("Error creating reservation repository:", err)
+	}
+
+	// Mirror writes onto a second backend ahead of switching to it
+	var shadowRepository *shadow_repository.ProductRepository
+	if cfg.Database.ShadowType != "" {
+		shadowRepository, err = newShadowRepository(cfg, productRepository)
+		if err != nil {
+			log.Fatal("Error creating shadow repository:", err)
+		}
+		productRepository = shadowRepository
+		reservationRepository = shadowRepository.Reservations(reservationRepository)
+		go shadowRepository.Run(context.Background())
+	}
+
+	// Serve hot product lookups from memory. The backend extras above
+	// were found through the concrete repository, so wrapping comes last.
+	var productCache *cache_repository.ProductRepository
+	if cfg.Cache.Enabled {
+		productCache = cache_repository.NewProductRepository(productRepository, cfg.Cache.Size, cfg.Cache.TTL)
+		productRepository = productCache
+		reservationRepository = productCache.Reservations(reservationRepository)
+	}
+	sweeper := application.NewReservationSweeper(reservationRepository,
+		application.WithSweepInterval(cfg.Reservations.SweepInterval),
+		application.WithSweeperAuditRepository(auditRepository),
+		application.WithSweeperErrorHandler(func(err error) {
+			log.Printf("Reservation sweeper: %v", err)
+		}),
+	)
+	go sweeper.Run(context.Background())
+
 	// Create the product service
-	productService := application.NewProductService(productRepository)
+	productService := application.NewProductService(productRepository,
+		application.WithAuditRepository(auditRepository),
+		application.WithAuditErrorHandler(func(err error) {
+			log.Printf("Audit: %v", err)
+		}),
+		application.WithReservationRepository(reservationRepository),
+		application.WithReservationTTL(cfg.Reservations.TTL))
 
 	// Create the product handlers
 	productHandlers := http.NewProductHandlers(productService)
 
 	// Initialize Fiber app
 	app := fiber.New()
+	app.Use(http.RequestContext(cfg.Server.RequestTimeout))
+	app.Use(http.Actor(cfg.Server.ActorHeader))
+	app.Use(http.PrimaryReads())
 
 	// Define routes
 	v1 := app.Group("/v1")
+	if productCache != nil {
+		v1.Get("/cache/stats", func(c *fiber.Ctx) error {
+			return c.JSON(productCache.Stats())
+		})
+	}
+	if shadowRepository != nil {
+		v1.Get("/shadow/stats", func(c *fiber.Ctx) error {
+			return c.JSON(shadowRepository.Stats())
+		})
+	}
 	productRoutes := v1.Group("/products")
 	productRoutes.Post("/", productHandlers.CreateProduct)
 	productRoutes.Get("/", productHandlers.GetAllProducts)
+	productRoutes.Get("/search", productHandlers.SearchProducts)
+	productRoutes.Get("/trash", productHandlers.ListTrash)
+	productRoutes.Get("/stream", productHandlers.StreamProducts)
+	productRoutes.Get("/export.csv", productHandlers.ExportProducts)
+	productRoutes.Post("/import", productHandlers.ImportProducts)
+	productRoutes.Post("/bulk", productHandlers.BulkCreateProducts)
+	productRoutes.Put("/bulk", productHandlers.BulkUpdateProducts)
+	productRoutes.Delete("/bulk", productHandlers.BulkDeleteProducts)
 	productRoutes.Get("/:id", productHandlers.GetProduct)
 	productRoutes.Put("/:id", productHandlers.UpdateProduct)
+	productRoutes.Post("/:id/stock/adjust", productHandlers.AdjustStock)
 	productRoutes.Delete("/:id", productHandlers.DeleteProduct)
+	productRoutes.Post("/:id/restore", productHandlers.RestoreProduct)
+	productRoutes.Delete("/:id/purge", productHandlers.PurgeProduct)
+	productRoutes.Get("/:id/history", productHandlers.ProductHistory)
+	productRoutes.Post("/:id/reservations", productHandlers.CreateReservation)
+	productRoutes.Post("/:id/reservations/:reservation_id/commit", productHandlers.CommitReservation)
+	productRoutes.Post("/:id/reservations/:reservation_id/release", productHandlers.ReleaseReservation)
 
 	// Start the server
 	err = app.Listen(fmt.Sprintf(":%d", cfg.Server.Port))
@@ -63,3 +161,191 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// newProductRepository connects to the backend dbType, configured in cfg.
+func newProductRepository(cfg config.Config, dbType string) (port.ProductRepository, error) {
+	switch dbType {
+	case "mysql":
+		return newMySQLRepository(cfg)
+	case "mongodb":
+		return newMongoDBRepository(cfg)
+	case "postgres":
+		return postgres_repository.NewProductRepository(cfg.Database.Postgres.DSN)
+	case "sqlite":
+		return sqlite_repository.NewProductRepository(cfg.Database.SQLite.Path)
+	case "memory":
+		return memory_repository.NewProductRepository(), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+}
+
+// newShadowRepository wraps primary so that its writes are mirrored onto
+// the DB_SHADOW_TYPE backend, logging every divergence.
+func newShadowRepository(cfg config.Config, primary port.ProductRepository) (*shadow_repository.ProductRepository, error) {
+	shadow, err := newProductRepository(cfg, cfg.Database.ShadowType)
+	if err != nil {
+		return nil, err
+	}
+	ids := shadow_repository.NewIDMap()
+	if cfg.Database.ShadowIDMap != "" {
+		if ids, err = shadow_repository.OpenIDMap(cfg.Database.ShadowIDMap); err != nil {
+			return nil, err
+		}
+	}
+	return shadow_repository.NewProductRepository(primary, shadow,
+		shadow_repository.WithIDMap(ids),
+		shadow_repository.WithDivergenceHandler(func(divergence shadow_repository.Divergence) {
+			log.Printf("Shadow divergence: %s", divergence)
+		}),
+	), nil
+}
+
+// newMySQLRepository connects to MySQL and, when enabled, brings the schema
+// up to date before the server starts taking requests.
+func newMySQLRepository(cfg config.Config) (*mysql_repository.ProductRepository, error) {
+	repository, err := mysql_repository.NewProductRepository(cfg.Database.MySQL.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Database.MySQL.ReplicaDSNs) > 0 {
+		if err := repository.UseReplicas(context.Background(), cfg.Database.MySQL.ReplicaDSNs...); err != nil {
+			return nil, err
+		}
+		go repository.RunReplicaChecks(context.Background(), cfg.Database.MySQL.ReplicaCheckInterval)
+	}
+	if !cfg.Database.MySQL.AutoMigrate {
+		return repository, nil
+	}
+
+	migrator, err := repository.Migrator()
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateUp(context.Background(), migrator); err != nil {
+		return nil, err
+	}
+	return repository, nil
+}
+
+// newMongoDBRepository connects to MongoDB and reconciles the collection
+// indexes unless that has been switched off.
+func newMongoDBRepository(cfg config.Config) (*mongodb_repository.ProductRepository, error) {
+	repository, err := mongodb_repository.NewProductRepository(
+		cfg.Database.MongoDB.URI,
+		cfg.Database.MongoDB.Database,
+		cfg.Database.MongoDB.Collection,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Database.MongoDB.EnsureIndexes {
+		return repository, nil
+	}
+
+	report, err := repository.EnsureIndexes(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("MongoDB indexes reconciled: %s", report)
+	return repository, nil
+}
+
+// newAuditRepository returns the audit repository of the backend behind
+// repository.
+func newAuditRepository(cfg config.Config, repository port.ProductRepository) (port.AuditRepository, error) {
+	switch repository := repository.(type) {
+	case *mysql_repository.ProductRepository:
+		return repository.AuditRepository(), nil
+	case *mongodb_repository.ProductRepository:
+		audit := repository.AuditRepository()
+		if cfg.Database.MongoDB.EnsureIndexes {
+			if err := audit.EnsureIndexes(context.Background()); err != nil {
+				return nil, err
+			}
+		}
+		return audit, nil
+	case *postgres_repository.ProductRepository:
+		return repository.AuditRepository(), nil
+	case *sqlite_repository.ProductRepository:
+		return repository.AuditRepository(), nil
+	case *memory_repository.ProductRepository:
+		return memory_repository.NewAuditRepository(), nil
+	default:
+		return nil, fmt.Errorf("no audit repository for %T", repository)
+	}
+}
+
+// newReservationRepository returns the reservations kept by the backend
+// behind repository.
+func newReservationRepository(cfg config.Config, repository port.ProductRepository) (port.ReservationRepository, error) {
+	switch repository := repository.(type) {
+	case *mysql_repository.ProductRepository:
+		return repository.Reservations(), nil
+	case *mongodb_repository.ProductRepository:
+		reservations := repository.Reservations()
+		if cfg.Database.MongoDB.EnsureIndexes {
+			if err := reservations.EnsureIndexes(context.Background()); err != nil {
+				return nil, err
+			}
+		}
+		return reservations, nil
+	case *postgres_repository.ProductRepository:
+		return repository.Reservations(), nil
+	case *sqlite_repository.ProductRepository:
+		return repository.Reservations(), nil
+	case *memory_repository.ProductRepository:
+		return repository.Reservations(), nil
+	default:
+		return nil, fmt.Errorf("no reservation repository for %T", repository)
+	}
+}
+
+// outboxRepository is implemented by every product repository that can
+// record events in the same transaction as its writes.
+type outboxRepository interface {
+	EnableOutbox()
+}
+
+// newOutboxRelay switches on event recording for repository and returns
+// a relay from its outbox to the configured publisher.
+func newOutboxRelay(cfg config.Config, repository port.ProductRepository) (*application.OutboxRelay, error) {
+	var outbox port.OutboxRepository
+	switch repository := repository.(type) {
+	case *mysql_repository.ProductRepository:
+		outbox = repository.Outbox()
+	case *mongodb_repository.ProductRepository:
+		mongoOutbox := repository.Outbox()
+		if cfg.Database.MongoDB.EnsureIndexes {
+			if err := mongoOutbox.EnsureIndexes(context.Background()); err != nil {
+				return nil, err
+			}
+		}
+		outbox = mongoOutbox
+	case *postgres_repository.ProductRepository:
+		outbox = repository.Outbox()
+	case *sqlite_repository.ProductRepository:
+		outbox = repository.Outbox()
+	case *memory_repository.ProductRepository:
+		outbox = repository.Outbox()
+	default:
+		return nil, fmt.Errorf("no outbox for %T", repository)
+	}
+	repository.(outboxRepository).EnableOutbox()
+
+	var eventPublisher port.EventPublisher
+	switch cfg.Outbox.Publisher {
+	case "webhook":
+		eventPublisher = publisher.NewWebhookPublisher(cfg.Outbox.WebhookURL, &nethttp.Client{Timeout: 10 * time.Second})
+	default:
+		eventPublisher = publisher.NewLogPublisher(log.Default())
+	}
+
+	return application.NewOutboxRelay(outbox, eventPublisher,
+		application.WithBatchSize(cfg.Outbox.BatchSize),
+		application.WithPollInterval(cfg.Outbox.PollInterval),
+		application.WithRelayErrorHandler(func(err error) {
+			log.Printf("Outbox relay: %v", err)
+		}),
+	), nil
+}
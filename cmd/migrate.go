@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"goproduct/internals/adapter/repository/mysql_repository"
+	"goproduct/internals/config"
+)
+
+// runMigrate implements the "migrate up|down|status" command for the
+// MySQL backend.
+func runMigrate(cfg config.Config, args []string) error {
+	if cfg.Database.Type != "mysql" {
+		return fmt.Errorf("migrations are only supported for DB_TYPE=mysql, got %s", cfg.Database.Type)
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: migrate up|down|status")
+	}
+
+	ctx := context.Background()
+	repository, err := mysql_repository.NewProductRepository(cfg.Database.MySQL.DSN)
+	if err != nil {
+		return err
+	}
+	migrator, err := repository.Migrator()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, migrator)
+	case "down":
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if migration == nil {
+			log.Println("No migrations to roll back")
+			return nil
+		}
+		log.Printf("Rolled back migration %04d_%s", migration.Version, migration.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+	return nil
+}
+
+// migrateUp applies every pending migration and logs what it did.
+func migrateUp(ctx context.Context, migrator *mysql_repository.Migrator) error {
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		log.Println("Database schema is up to date")
+	}
+	for _, migration := range applied {
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+	}
+	return nil
+}
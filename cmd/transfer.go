@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"goproduct/internals/config"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+)
+
+// runTransfer implements the "transfer -from TYPE -to TYPE" command, which
+// copies the catalog between two backends configured in the environment.
+// Progress is checkpointed to a file after every batch, so running the
+// same command again resumes where it stopped, and the new ID of every
+// product is appended to a CSV file. It ends with a verification report
+// and fails when source and target differ.
+func runTransfer(cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("transfer", flag.ContinueOnError)
+	from := flags.String("from", "", "source backend: mysql, mongodb, postgres or sqlite")
+	to := flags.String("to", "", "target backend: mysql, mongodb, postgres or sqlite")
+	batchSize := flags.Int("batch", application.DefaultTransferBatchSize, "products per batch")
+	checkpointPath := flags.String("checkpoint", "transfer-checkpoint.json", "file recording progress")
+	idMapPath := flags.String("id-map", "transfer-ids.csv", "file recording source and target IDs")
+	verifyOnly := flags.Bool("verify", false, "only compare source and target")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" || *from == *to {
+		return fmt.Errorf("usage: transfer -from TYPE -to TYPE, with two different backends")
+	}
+	if *from == "memory" || *to == "memory" {
+		return fmt.Errorf("the memory backend does not outlive the command")
+	}
+	if *batchSize <= 0 {
+		return fmt.Errorf("invalid batch size %d", *batchSize)
+	}
+
+	// Replica lag must not hide products from the copy or the report
+	ctx := domain.WithPrimaryRead(context.Background())
+	source, err := openTransferRepository(cfg, *from)
+	if err != nil {
+		return fmt.Errorf("open source: %w", err)
+	}
+	target, err := openTransferRepository(cfg, *to)
+	if err != nil {
+		return fmt.Errorf("open target: %w", err)
+	}
+
+	if !*verifyOnly {
+		if err := transfer(ctx, source, target, *batchSize, *checkpointPath, *idMapPath); err != nil {
+			return err
+		}
+	}
+
+	report, err := application.NewProductTransfer(source, target).Verify(ctx)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(encoded))
+	if !report.Match {
+		return errors.New("source and target differ")
+	}
+	return nil
+}
+
+// openTransferRepository loads the settings of dbType and connects to it.
+func openTransferRepository(cfg config.Config, dbType string) (port.ProductRepository, error) {
+	if err := config.LoadDatabaseConfig(&cfg, dbType); err != nil {
+		return nil, err
+	}
+	return newProductRepository(cfg, dbType)
+}
+
+func transfer(ctx context.Context, source, target port.ProductRepository, batchSize int, checkpointPath, idMapPath string) error {
+	checkpoint, resumed, err := loadCheckpoint(checkpointPath)
+	if err != nil {
+		return err
+	}
+	if checkpoint.Done {
+		log.Printf("Transfer already complete with %d products", checkpoint.Copied)
+		return nil
+	}
+	if resumed {
+		log.Printf("Resuming transfer after %d products", checkpoint.Copied)
+	} else {
+		// Without a checkpoint, products already in the target would only
+		// make the copy impossible to verify.
+		page, err := target.ListProducts(ctx, port.ProductQuery{Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(page.Products) > 0 {
+			return errors.New("target already holds products; transfer into an empty backend")
+		}
+	}
+
+	idMap, err := os.OpenFile(idMapPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer idMap.Close()
+	ids := csv.NewWriter(idMap)
+	if !resumed {
+		if err := ids.Write([]string{"source_id", "target_id"}); err != nil {
+			return err
+		}
+	}
+
+	run := application.NewProductTransfer(source, target,
+		application.WithTransferBatchSize(batchSize),
+		application.WithIDMappings(func(mappings []application.IDMapping) error {
+			for _, mapping := range mappings {
+				if err := ids.Write([]string{mapping.Source.String(), mapping.Target.String()}); err != nil {
+					return err
+				}
+			}
+			ids.Flush()
+			if err := ids.Error(); err != nil {
+				return err
+			}
+			// The IDs must be on disk before the checkpoint moves past them
+			return idMap.Sync()
+		}),
+		application.WithCheckpointSaver(func(checkpoint application.TransferCheckpoint) error {
+			log.Printf("Copied %d products", checkpoint.Copied)
+			return saveCheckpoint(checkpointPath, checkpoint)
+		}),
+	)
+	checkpoint, err = run.Run(ctx, checkpoint)
+	if err != nil {
+		return fmt.Errorf("transfer stopped after %d products, run again to resume: %w", checkpoint.Copied, err)
+	}
+	log.Printf("Transfer complete with %d products", checkpoint.Copied)
+	return nil
+}
+
+// loadCheckpoint reads the checkpoint at path, reporting whether there was
+// one.
+func loadCheckpoint(path string) (application.TransferCheckpoint, bool, error) {
+	var checkpoint application.TransferCheckpoint
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return checkpoint, false, nil
+	}
+	if err != nil {
+		return checkpoint, false, err
+	}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return checkpoint, false, fmt.Errorf("read checkpoint %s: %w", path, err)
+	}
+	return checkpoint, true, nil
+}
+
+// saveCheckpoint replaces the checkpoint at path through a rename, so a
+// crash never leaves half a checkpoint behind.
+func saveCheckpoint(path string, checkpoint application.TransferCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"errors"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"net/http"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// bulkItemResponse is the JSON shape of a port.BulkResult. Status is the
+// HTTP status the item would have had as a single request.
+type bulkItemResponse struct {
+	Index   int              `json:"index"`
+	Status  int              `json:"status"`
+	ID      domain.ProductID `json:"id,omitempty"`
+	Version int64            `json:"version,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// BulkCreateProducts handles creating many products from
+// {"atomic": bool, "items": [product, ...]}.
+func (h *ProductHandlers) BulkCreateProducts(c *fiber.Ctx) error {
+	var request struct {
+		Atomic bool              `json:"atomic"`
+		Items  []*domain.Product `json:"items"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	results, err := h.productService.BulkCreateProducts(c.UserContext(), request.Items, request.Atomic)
+	if err != nil {
+		return errorResponse(c, err, "Failed to create products")
+	}
+	return bulkResponse(c, results, http.StatusCreated, "Products created successfully")
+}
+
+// BulkUpdateProducts handles updating many products from
+// {"atomic": bool, "items": [product, ...]}. Each item names its product by
+// id and may carry the version it expects.
+func (h *ProductHandlers) BulkUpdateProducts(c *fiber.Ctx) error {
+	var request struct {
+		Atomic bool              `json:"atomic"`
+		Items  []*domain.Product `json:"items"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	for _, product := range request.Items {
+		if product != nil {
+			product.DeletedAt = nil
+		}
+	}
+
+	results, err := h.productService.BulkUpdateProducts(c.UserContext(), request.Items, request.Atomic)
+	if err != nil {
+		return errorResponse(c, err, "Failed to update products")
+	}
+	return bulkResponse(c, results, http.StatusOK, "Products updated successfully")
+}
+
+// BulkDeleteProducts handles moving many products to the trash from
+// {"atomic": bool, "items": [{"id": ..., "version": ...}, ...]}.
+func (h *ProductHandlers) BulkDeleteProducts(c *fiber.Ctx) error {
+	var request struct {
+		Atomic bool `json:"atomic"`
+		Items  []struct {
+			ID      domain.ProductID `json:"id"`
+			Version int64            `json:"version"`
+		} `json:"items"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	items := make([]port.BulkDelete, len(request.Items))
+	for i, item := range request.Items {
+		items[i] = port.BulkDelete{ID: item.ID, Version: item.Version}
+	}
+	results, err := h.productService.BulkDeleteProducts(c.UserContext(), items, request.Atomic)
+	if err != nil {
+		return errorResponse(c, err, "Failed to delete products")
+	}
+	return bulkResponse(c, results, http.StatusOK, "Products deleted successfully")
+}
+
+// bulkResponse answers with status when every item was applied and with
+// 207 Multi-Status otherwise, listing the outcome of each item.
+func bulkResponse(c *fiber.Ctx, results []port.BulkResult, status int, message string) error {
+	items := make([]bulkItemResponse, len(results))
+	failed := 0
+	for i, result := range results {
+		item := bulkItemResponse{Index: result.Index, Status: status}
+		if result.Product != nil {
+			item.ID = result.Product.ID
+			item.Version = result.Product.Version
+		}
+		if result.Err != nil {
+			failed++
+			item.Status = bulkItemStatus(result.Err)
+			item.Error = result.Err.Error()
+			if item.Status == http.StatusInternalServerError {
+				item.Error = "internal error"
+			}
+		}
+		items[i] = item
+	}
+
+	if failed > 0 {
+		status = http.StatusMultiStatus
+		message = "Some items failed"
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"status_code": status,
+		"message":     message,
+		"data":        items,
+		"succeeded":   len(results) - failed,
+		"failed":      failed,
+	})
+}
+
+// bulkItemStatus extends statusFor with 424 Failed Dependency for items
+// skipped because another item of an atomic request failed.
+func bulkItemStatus(err error) int {
+	if errors.Is(err, domain.ErrBulkAborted) {
+		return http.StatusFailedDependency
+	}
+	return statusFor(err)
+}
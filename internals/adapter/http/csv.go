@@ -0,0 +1,290 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// csvColumns is the header of an export. An import reads the same columns
+// by name, ignoring reserved, which only moves through reservations.
+var csvColumns = []string{"id", "product_name", "price", "stock", "reserved", "version"}
+
+// formulaPrefixes are the first characters that make a spreadsheet read a
+// cell as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// csvLineError is one entry of an import's error report.
+type csvLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ExportProducts handles streaming the live catalog as CSV, ordered by ID.
+// The first page is read before anything is sent so that a failing backend
+// still gets a proper error status; later pages are read as the client
+// consumes the body.
+func (h *ProductHandlers) ExportProducts(c *fiber.Ctx) error {
+	// The stream outlives the handler and with it the request timeout.
+	ctx := context.WithoutCancel(c.UserContext())
+	query := port.ProductQuery{
+		Sort:  []port.SortKey{{Field: port.SortByID}},
+		Limit: application.MaxPageSize,
+	}
+	page, err := h.productService.ListProducts(ctx, query)
+	if err != nil {
+		return errorResponse(c, err, "Failed to export products")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="products.csv"`)
+	c.Status(http.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		out := csv.NewWriter(w)
+		_ = out.Write(csvColumns)
+		for {
+			for _, product := range page.Products {
+				_ = out.Write([]string{
+					product.ID.String(),
+					escapeCSVCell(product.ProductName),
+					strconv.FormatFloat(product.Price, 'f', -1, 64),
+					strconv.Itoa(product.Stock),
+					strconv.Itoa(product.Reserved),
+					strconv.FormatInt(product.Version, 10),
+				})
+			}
+			out.Flush()
+			// A failed flush means the client went away.
+			if out.Error() != nil || w.Flush() != nil || page.NextCursor == "" {
+				return
+			}
+
+			query.Cursor = page.NextCursor
+			if page, err = h.productService.ListProducts(ctx, query); err != nil {
+				// The status is already sent; a truncated body is all
+				// that is left to signal the failure.
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// ImportProducts handles a CSV upload, either as the "file" field of a
+// multipart form or as the raw request body. The header row names the
+// columns: product_name, price and stock are required; a row with an id
+// updates that product, at its version if the row has one, and a row
+// without creates a new product. Unknown columns are ignored, so an export
+// can be edited and uploaded as is. Rows are applied one by one and every
+// failing row is reported by its line number. With ?dry_run=true the rows
+// are only checked.
+func (h *ProductHandlers) ImportProducts(c *fiber.Ctx) error {
+	dryRun := c.QueryBool("dry_run")
+
+	body, err := importBody(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid upload",
+		})
+	}
+
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Missing CSV header",
+		})
+	}
+	columns, err := csvHeader(header)
+	if err != nil {
+		return errorResponse(c, err, "Invalid CSV header")
+	}
+
+	var products []*domain.Product
+	var lines []int
+	var report []csvLineError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			report = append(report, csvLineError{Line: parseErr.StartLine, Error: "wrong number of fields"})
+			continue
+		}
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Malformed CSV: " + err.Error(),
+			})
+		}
+
+		line, _ := reader.FieldPos(0)
+		product, err := csvProduct(record, columns)
+		if err != nil {
+			report = append(report, csvLineError{Line: line, Error: err.Error()})
+			continue
+		}
+		products = append(products, product)
+		lines = append(lines, line)
+	}
+
+	// The service fills in the ID of created products, so which rows are
+	// creates is noted first.
+	creates := make([]bool, len(products))
+	for i, product := range products {
+		creates[i] = product.ID.IsZero()
+	}
+	var results []port.BulkResult
+	if len(products) > 0 || len(report) == 0 {
+		if results, err = h.productService.ImportProducts(c.UserContext(), products, dryRun); err != nil {
+			return errorResponse(c, err, "Failed to import products")
+		}
+	}
+
+	created, updated := 0, 0
+	for i, result := range results {
+		if result.Err != nil {
+			message := result.Err.Error()
+			if statusFor(result.Err) == http.StatusInternalServerError {
+				message = "internal error"
+			}
+			report = append(report, csvLineError{Line: lines[i], Error: message})
+		} else if creates[i] {
+			created++
+		} else {
+			updated++
+		}
+	}
+	slices.SortStableFunc(report, func(a, b csvLineError) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+
+	status, message := http.StatusOK, "Products imported successfully"
+	if dryRun {
+		message = "Dry run passed"
+	}
+	if len(report) > 0 {
+		status, message = http.StatusMultiStatus, "Some rows failed"
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"status_code": status,
+		"message":     message,
+		"dry_run":     dryRun,
+		"created":     created,
+		"updated":     updated,
+		"failed":      len(report),
+		"errors":      report,
+	})
+}
+
+// importBody returns the uploaded CSV: the "file" form field of a
+// multipart request, the raw body otherwise.
+func importBody(c *fiber.Ctx) (io.Reader, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		return bytes.NewReader(c.Body()), nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	// Reading the file up front keeps the handler free of cleanup paths;
+	// the upload is bounded by the server's body limit anyway.
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// csvHeader maps the column names of header to their positions and checks
+// that the required ones are there.
+func csvHeader(header []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		// Spreadsheet tools like to start the file with a byte order mark.
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name == "" {
+			continue
+		}
+		if _, ok := columns[name]; ok {
+			return nil, domain.NewValidationError("header", fmt.Sprintf("column %q appears twice", name))
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"product_name", "price", "stock"} {
+		if _, ok := columns[name]; !ok {
+			return nil, domain.NewValidationError("header", fmt.Sprintf("column %q is required", name))
+		}
+	}
+	return columns, nil
+}
+
+// csvProduct builds a product from one record. Only malformed fields are
+// reported here; the product rules are left to the service.
+func csvProduct(record []string, columns map[string]int) (*domain.Product, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	product := &domain.Product{
+		ID:          domain.ProductID(field("id")),
+		ProductName: unescapeCSVCell(field("product_name")),
+	}
+	var err error
+	if product.Price, err = strconv.ParseFloat(field("price"), 64); err != nil {
+		return nil, fmt.Errorf("price: %q is not a number", field("price"))
+	}
+	if product.Stock, err = strconv.Atoi(field("stock")); err != nil {
+		return nil, fmt.Errorf("stock: %q is not a whole number", field("stock"))
+	}
+	if version := field("version"); version != "" {
+		if product.Version, err = strconv.ParseInt(version, 10, 64); err != nil {
+			return nil, fmt.Errorf("version: %q is not a whole number", version)
+		}
+	}
+	return product, nil
+}
+
+// escapeCSVCell quotes a cell that a spreadsheet would otherwise evaluate
+// as a formula by prefixing it with an apostrophe. A cell that already
+// starts with one gets another, so that unescapeCSVCell can tell the two
+// apart.
+func escapeCSVCell(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes+"'", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCSVCell undoes escapeCSVCell, so that an exported file imports
+// unchanged. Like a spreadsheet, it also takes the apostrophe off a cell
+// written by hand as '=..., keeping the formula text as the value.
+func unescapeCSVCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes+"'", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"errors"
+	"goproduct/internals/core/product/domain"
+	"net/http"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// statusFor maps the domain error taxonomy onto HTTP status codes.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorResponse writes err with the status from statusFor. Domain errors
+// are safe to show to clients as-is; anything else is reported with the
+// given fallback message.
+func errorResponse(c *fiber.Ctx, err error, fallback string) error {
+	status := statusFor(err)
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = fallback
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"message": message,
+	})
+}
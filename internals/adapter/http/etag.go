@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// etag renders a product version as a strong entity tag.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatch reports whether the request's If-Match header, if any, admits
+// the given version. The second result is false when no header was sent.
+// Weak tags are compared by their opaque value, as the version is the
+// only thing a tag encodes.
+func ifMatch(c *fiber.Ctx, version int64) (matched, present bool) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" {
+		return false, false
+	}
+	if header == "*" {
+		return true, true
+	}
+
+	want := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == want {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// preconditionFailed answers a request whose If-Match header no longer
+// matches the stored product.
+func preconditionFailed(c *fiber.Ctx) error {
+	return c.Status(http.StatusPreconditionFailed).JSON(fiber.Map{
+		"message": "Product has been modified",
+	})
+}
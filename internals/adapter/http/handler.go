@@ -3,12 +3,16 @@ package http
 import (
 	"goproduct/internals/core/product/domain"
 	"goproduct/internals/core/product/port"
-	"strconv"
 
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	fiber "github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/gofiber/fiber/v2/utils"
 )
 
 type ProductHandlers struct {
@@ -23,6 +27,13 @@ func NewProductHandlers(productService port.ProductService) *ProductHandlers {
 	}
 }
 
+// productIDParam reads the :id route parameter. Fiber hands out strings
+// backed by the request buffer, so the value is copied before it can
+// outlive the request.
+func productIDParam(c *fiber.Ctx) domain.ProductID {
+	return domain.ProductID(utils.CopyString(c.Params("id")))
+}
+
 // CreateProduct handles the creation of a new product
 func (h *ProductHandlers) CreateProduct(c *fiber.Ctx) error {
 	var product domain.Product
@@ -33,24 +44,27 @@ func (h *ProductHandlers) CreateProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.productService.CreateProduct(&product)
+	err := h.productService.CreateProduct(c.UserContext(), &product)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to create product: " + err.Error(),
-		})
+		return errorResponse(c, err, "Failed to create product")
 	}
 
 	type ProductResponse struct {
-		ProductName string  `json:"product_name"`
-		Price       float64 `json:"price"`
-		Stock       int     `json:"stock"`
+		ID          domain.ProductID `json:"id"`
+		ProductName string           `json:"product_name"`
+		Price       float64          `json:"price"`
+		Stock       int              `json:"stock"`
+		Version     int64            `json:"version"`
 	}
 
 	response := ProductResponse{
+		ID:          product.ID,
 		ProductName: product.ProductName,
 		Price:       product.Price,
 		Stock:       product.Stock,
+		Version:     product.Version,
 	}
+	c.Set(fiber.HeaderETag, etag(product.Version))
 	return c.Status(http.StatusCreated).JSON(fiber.Map{
 		"status_code": http.StatusCreated,
 		"message":     "Product created successfully",
@@ -58,30 +72,17 @@ func (h *ProductHandlers) CreateProduct(c *fiber.Ctx) error {
 	})
 }
 
-// GetProduct handles retrieving a product by its ID
+// GetProduct handles retrieving a product by its ID. The ETag header
+// carries the product version for use with If-Match.
 func (h *ProductHandlers) GetProduct(c *fiber.Ctx) error {
-	productIDStr := c.Params("id")
-	var productID interface{}
-	var err error
+	productID := productIDParam(c)
 
-	productID, err = primitive.ObjectIDFromHex(productIDStr)
+	product, err := h.productService.GetProductByID(c.UserContext(), productID)
 	if err != nil {
-		productID, err = strconv.Atoi(productIDStr)
-		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-				"message": "Invalid product ID",
-			})
-		}
+		return errorResponse(c, err, "Failed to get product")
 	}
 
-	product, err := h.productService.GetProductByID(productID)
-	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to get product"})
-	}
-
-	if product == nil {
-		return c.Status(http.StatusNotFound).JSON(fiber.Map{"message": "Product not found"})
-	}
+	c.Set(fiber.HeaderETag, etag(product.Version))
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
 		"status_code": http.StatusOK,
@@ -90,62 +91,125 @@ func (h *ProductHandlers) GetProduct(c *fiber.Ctx) error {
 	})
 }
 
-// GetAllProducts handles retrieving all products
+// GetAllProducts handles listing products one page at a time. Besides
+// ?limit= and the opaque ?cursor= returned as next_cursor, it accepts the
+// filters price_min, price_max, stock_lt and name_contains, and a sort
+// such as ?sort=price,-stock.
 func (h *ProductHandlers) GetAllProducts(c *fiber.Ctx) error {
-	products, err := h.productService.GetAllProducts()
+	query, err := parseProductQuery(c)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to get all products",
-		})
+		return errorResponse(c, err, "Invalid query")
+	}
+
+	page, err := h.productService.ListProducts(c.UserContext(), query)
+	if err != nil {
+		return errorResponse(c, err, "Failed to get all products")
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
 		"status_code": http.StatusOK,
 		"message":     "Get all data success!",
+		"data":        page.Products,
+		"total":       len(page.Products),
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// streamFlushEvery is how many products StreamProducts buffers before
+// pushing them to the client.
+const streamFlushEvery = 100
+
+// StreamProducts handles exporting every live product as newline-delimited
+// JSON, one product per line, written as the repository reads them. The
+// status is sent before the first row, so a failure part way through ends
+// the stream with a final {"error": ...} line instead.
+func (h *ProductHandlers) StreamProducts(c *fiber.Ctx) error {
+	// The stream outlives the handler and with it the request timeout.
+	ctx := context.WithoutCancel(c.UserContext())
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Status(http.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		encoder := json.NewEncoder(w)
+		var clientErr error
+		n := 0
+		err := h.productService.StreamProducts(ctx, func(product *domain.Product) error {
+			if clientErr = encoder.Encode(product); clientErr != nil {
+				return clientErr
+			}
+			if n++; n%streamFlushEvery == 0 {
+				clientErr = w.Flush()
+			}
+			return clientErr
+		})
+		if err != nil && clientErr == nil {
+			_ = encoder.Encode(fiber.Map{"error": "stream interrupted"})
+		}
+		_ = w.Flush()
+	})
+	return nil
+}
+
+// SearchProducts handles full-text search over product names via ?q=,
+// returning at most ?limit= products ordered by relevance.
+func (h *ProductHandlers) SearchProducts(c *fiber.Ctx) error {
+	limit, err := intParam(c, "limit")
+	if err != nil {
+		return errorResponse(c, err, "Invalid query")
+	}
+
+	products, err := h.productService.SearchProducts(c.UserContext(), utils.CopyString(c.Query("q")), limit)
+	if err != nil {
+		return errorResponse(c, err, "Failed to search products")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"status_code": http.StatusOK,
+		"message":     "Search success!",
 		"data":        products,
 		"total":       len(products),
 	})
 }
 
-// UpdateProduct handles updating an existing product
+// UpdateProduct handles updating an existing product. With an If-Match
+// header the update only applies to the tagged version and fails with 412
+// otherwise; without one it applies to the version read here, so a
+// concurrent write still surfaces as 409.
 func (h *ProductHandlers) UpdateProduct(c *fiber.Ctx) error {
-	productIDStr := c.Params("id")
-	var productID interface{}
-	var err error
+	productID := productIDParam(c)
 
-	productID, err = primitive.ObjectIDFromHex(productIDStr)
+	product, err := h.productService.GetProductByID(c.UserContext(), productID)
 	if err != nil {
-		productID, err = strconv.Atoi(productIDStr)
-		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-				"message": "Invalid product ID",
-			})
-		}
+		return errorResponse(c, err, "Failed to get product")
 	}
-
-	product, err := h.productService.GetProductByID(productID)
-	if err != nil {
-		if err.Error() == "product not found" { // Or use a custom error type
-			return c.Status(http.StatusNotFound).JSON(fiber.Map{"message": "Product not found"})
-		}
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to get product"})
+	matched, conditional := ifMatch(c, product.Version)
+	if conditional && !matched {
+		return preconditionFailed(c)
 	}
+
+	version, reserved := product.Version, product.Reserved
 	if err := c.BodyParser(&product); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"message": "Invalid request body",
 		})
 	}
 
-	// Set the ProductID from the URL parameter
-	product.ID = &productID
+	// Set the ProductID from the URL parameter and keep the version and
+	// reserved quantity read above, whatever the body says
+	product.ID = productID
+	product.Version = version
+	product.Reserved = reserved
+	product.DeletedAt = nil
 
-	err = h.productService.UpdateProduct(product)
+	err = h.productService.UpdateProduct(c.UserContext(), product)
+	if conditional && errors.Is(err, domain.ErrVersionMismatch) {
+		return preconditionFailed(c)
+	}
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to update product: " + err.Error(),
-		})
+		return errorResponse(c, err, "Failed to update product")
 	}
 
+	c.Set(fiber.HeaderETag, etag(product.Version))
+
 	return c.JSON(fiber.Map{
 		"status_code": http.StatusOK,
 		"message":     "Product updated successfully",
@@ -153,32 +217,59 @@ func (h *ProductHandlers) UpdateProduct(c *fiber.Ctx) error {
 	})
 }
 
-// DeleteProduct handles deleting a product by its ID
-func (h *ProductHandlers) DeleteProduct(c *fiber.Ctx) error {
-	productIDStr := c.Params("id")
-	var productID interface{}
-	var err error
+// AdjustStock handles adding a signed delta to the stock of a product. The
+// body carries the delta and a reason code; an adjustment that would take
+// the stock below zero is refused with 409.
+func (h *ProductHandlers) AdjustStock(c *fiber.Ctx) error {
+	productID := productIDParam(c)
 
-	productID, err = primitive.ObjectIDFromHex(productIDStr)
+	var request struct {
+		Delta  int                `json:"delta"`
+		Reason domain.StockReason `json:"reason"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	product, err := h.productService.AdjustStock(c.UserContext(), productID, request.Delta, request.Reason)
 	if err != nil {
-		productID, err = strconv.Atoi(productIDStr)
+		return errorResponse(c, err, "Failed to adjust stock")
+	}
+
+	c.Set(fiber.HeaderETag, etag(product.Version))
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"status_code": http.StatusOK,
+		"message":     "Stock adjusted successfully",
+		"data":        product,
+	})
+}
+
+// DeleteProduct handles moving a product to the trash. With an If-Match
+// header the delete only applies to the tagged version and fails with 412
+// otherwise.
+func (h *ProductHandlers) DeleteProduct(c *fiber.Ctx) error {
+	productID := productIDParam(c)
+
+	var version int64
+	if c.Get(fiber.HeaderIfMatch) != "" {
+		product, err := h.productService.GetProductByID(c.UserContext(), productID)
 		if err != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-				"message": "Invalid product ID",
-			})
+			return errorResponse(c, err, "Failed to get product")
 		}
+		if matched, _ := ifMatch(c, product.Version); !matched {
+			return preconditionFailed(c)
+		}
+		version = product.Version
 	}
 
-	err = h.productService.DeleteProduct(productID)
+	err := h.productService.DeleteProduct(c.UserContext(), productID, version)
+	if version != 0 && errors.Is(err, domain.ErrVersionMismatch) {
+		return preconditionFailed(c)
+	}
 	if err != nil {
-		if err.Error() == "product not found" {
-			return c.Status(http.StatusNotFound).JSON(fiber.Map{
-				"message": "Product not found",
-			})
-		}
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to delete product: " + err.Error(),
-		})
+		return errorResponse(c, err, "Failed to delete product")
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
@@ -186,3 +277,139 @@ func (h *ProductHandlers) DeleteProduct(c *fiber.Ctx) error {
 		"message":     "Delete product success!",
 	})
 }
+
+// ListTrash lists deleted products one page at a time, taking the same
+// query parameters as GetAllProducts.
+func (h *ProductHandlers) ListTrash(c *fiber.Ctx) error {
+	query, err := parseProductQuery(c)
+	if err != nil {
+		return errorResponse(c, err, "Invalid query")
+	}
+	query.Filter.Deleted = true
+
+	page, err := h.productService.ListProducts(c.UserContext(), query)
+	if err != nil {
+		return errorResponse(c, err, "Failed to get deleted products")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"status_code": http.StatusOK,
+		"message":     "Get deleted data success!",
+		"data":        page.Products,
+		"total":       len(page.Products),
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// RestoreProduct handles taking a product out of the trash
+func (h *ProductHandlers) RestoreProduct(c *fiber.Ctx) error {
+	productID := productIDParam(c)
+
+	product, err := h.productService.RestoreProduct(c.UserContext(), productID)
+	if err != nil {
+		return errorResponse(c, err, "Failed to restore product")
+	}
+
+	c.Set(fiber.HeaderETag, etag(product.Version))
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"status_code": http.StatusOK,
+		"message":     "Product restored successfully",
+		"data":        product,
+	})
+}
+
+// PurgeProduct handles permanently removing a product, whether or not it
+// is in the trash
+func (h *ProductHandlers) PurgeProduct(c *fiber.Ctx) error {
+	productID := productIDParam(c)
+
+	err := h.productService.PurgeProduct(c.UserContext(), productID)
+	if err != nil {
+		return errorResponse(c, err, "Failed to purge product")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"status_code": http.StatusOK,
+		"message":     "Purge product success!",
+	})
+}
+
+// ProductHistory handles listing the recorded changes of a product,
+// oldest first. It also answers for deleted and purged products.
+func (h *ProductHandlers) ProductHistory(c *fiber.Ctx) error {
+	productID := productIDParam(c)
+
+	entries, err := h.productService.ProductHistory(c.UserContext(), productID)
+	if err != nil {
+		return errorResponse(c, err, "Failed to get product history")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"status_code": http.StatusOK,
+		"message":     "Get history success!",
+		"data":        entries,
+		"total":       len(entries),
+	})
+}
+
+// CreateReservation handles holding stock of a product. The body carries
+// the quantity and optionally the hold duration in ttl_seconds.
+func (h *ProductHandlers) CreateReservation(c *fiber.Ctx) error {
+	productID := productIDParam(c)
+
+	var request struct {
+		Quantity   int `json:"quantity"`
+		TTLSeconds int `json:"ttl_seconds"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	ttl := time.Duration(request.TTLSeconds) * time.Second
+	reservation, err := h.productService.ReserveStock(c.UserContext(), productID, request.Quantity, ttl)
+	if err != nil {
+		return errorResponse(c, err, "Failed to reserve stock")
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"status_code": http.StatusCreated,
+		"message":     "Stock reserved successfully",
+		"data":        reservation,
+	})
+}
+
+// CommitReservation handles turning a held reservation into a sale.
+func (h *ProductHandlers) CommitReservation(c *fiber.Ctx) error {
+	reservation, err := h.productService.CommitReservation(c.UserContext(), productIDParam(c), reservationIDParam(c))
+	if err != nil {
+		return errorResponse(c, err, "Failed to commit reservation")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"status_code": http.StatusOK,
+		"message":     "Reservation committed successfully",
+		"data":        reservation,
+	})
+}
+
+// ReleaseReservation handles giving held stock back to the product.
+func (h *ProductHandlers) ReleaseReservation(c *fiber.Ctx) error {
+	reservation, err := h.productService.ReleaseReservation(c.UserContext(), productIDParam(c), reservationIDParam(c))
+	if err != nil {
+		return errorResponse(c, err, "Failed to release reservation")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"status_code": http.StatusOK,
+		"message":     "Reservation released successfully",
+		"data":        reservation,
+	})
+}
+
+// reservationIDParam reads the :reservation_id route parameter, copied
+// like productIDParam.
+func reservationIDParam(c *fiber.Ctx) string {
+	return utils.CopyString(c.Params("reservation_id"))
+}
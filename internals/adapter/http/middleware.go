@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"strings"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// RequestContext gives every request a user context bounded by timeout, so
+// database work started by a handler is cancelled once the deadline passes.
+// A zero timeout leaves the context without a deadline.
+func RequestContext(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// Actor attributes the changes made by a request to the caller named in
+// the given header, for the audit trail. Requests without the header are
+// recorded as domain.AnonymousActor.
+func Actor(header string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if actor := strings.TrimSpace(c.Get(header)); actor != "" {
+			c.SetUserContext(domain.WithActor(c.UserContext(), utils.CopyString(actor)))
+		}
+		return c.Next()
+	}
+}
+
+// PrimaryReadHeader lets a client that has just written ask for its next
+// reads to see the write, by sending it with the value true.
+const PrimaryReadHeader = "X-Read-Primary"
+
+// PrimaryReads marks requests that must not be served from a lagging read
+// replica: every request that writes, since it reads the product it
+// changes, and reads that ask for it with PrimaryReadHeader.
+func PrimaryReads() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !isSafeMethod(c.Method()) || c.Get(PrimaryReadHeader) == "true" {
+			c.SetUserContext(domain.WithPrimaryRead(c.UserContext()))
+		}
+		return c.Next()
+	}
+}
+
+func isSafeMethod(method string) bool {
+	return method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions
+}
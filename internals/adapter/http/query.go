@@ -0,0 +1,66 @@
+package http
+
+import (
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"strconv"
+
+	fiber "github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// parseProductQuery reads the list parameters of GET /products:
+// limit, cursor, price_min, price_max, stock_lt, name_contains and sort.
+func parseProductQuery(c *fiber.Ctx) (port.ProductQuery, error) {
+	var query port.ProductQuery
+	var err error
+
+	if query.Limit, err = intParam(c, "limit"); err != nil {
+		return query, err
+	}
+	query.Cursor = utils.CopyString(c.Query("cursor"))
+
+	if query.Filter.PriceMin, err = floatParam(c, "price_min"); err != nil {
+		return query, err
+	}
+	if query.Filter.PriceMax, err = floatParam(c, "price_max"); err != nil {
+		return query, err
+	}
+	if c.Query("stock_lt") != "" {
+		stockLT, err := intParam(c, "stock_lt")
+		if err != nil {
+			return query, err
+		}
+		query.Filter.StockLT = &stockLT
+	}
+	query.Filter.NameContains = utils.CopyString(c.Query("name_contains"))
+
+	if query.Sort, err = port.ParseSort(c.Query("sort")); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+func intParam(c *fiber.Ctx, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, domain.NewValidationError(name, "invalid "+name)
+	}
+	return n, nil
+}
+
+func floatParam(c *fiber.Ctx, name string) (*float64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, domain.NewValidationError(name, "invalid "+name)
+	}
+	return &f, nil
+}
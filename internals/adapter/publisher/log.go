@@ -0,0 +1,32 @@
+// Package publisher holds the port.EventPublisher implementations the
+// outbox relay can deliver product events to.
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"log"
+)
+
+// LogPublisher writes every event as a JSON line to a logger. It is meant
+// for local runs and for checking what the relay would send.
+type LogPublisher struct {
+	logger *log.Logger
+}
+
+var _ port.EventPublisher = (*LogPublisher)(nil)
+
+func NewLogPublisher(logger *log.Logger) *LogPublisher {
+	return &LogPublisher{logger: logger}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event *domain.ProductEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.logger.Printf("product event: %s", payload)
+	return nil
+}
@@ -0,0 +1,50 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"net/http"
+)
+
+// WebhookPublisher POSTs every event as JSON to a URL. Any 2xx response
+// counts as delivered. The event ID is sent as Idempotency-Key, so that
+// receivers can drop the duplicates at-least-once delivery produces.
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+var _ port.EventPublisher = (*WebhookPublisher)(nil)
+
+func NewWebhookPublisher(url string, client *http.Client) *WebhookPublisher {
+	return &WebhookPublisher{url: url, client: client}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event *domain.ProductEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.ID)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
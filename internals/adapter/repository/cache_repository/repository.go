@@ -0,0 +1,246 @@
+// Package cache_repository wraps a port.ProductRepository with a
+// read-through cache for FindProductByID. Entries are bounded in number,
+// evicted least recently used first, and expire after a TTL. Concurrent
+// misses on the same product share one backend read, and every write
+// through the wrapper drops the products it touched.
+package cache_repository
+
+import (
+	"container/list"
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Stats counts cache activity since the repository was created.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+type entry struct {
+	id        domain.ProductID
+	product   domain.Product
+	expiresAt time.Time
+}
+
+// ProductRepository caches the products read through FindProductByID.
+// Methods it does not override go straight to the wrapped repository.
+type ProductRepository struct {
+	port.ProductRepository
+
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+	loads    singleflight.Group
+
+	mu      sync.Mutex
+	entries map[domain.ProductID]*list.Element
+	// recency holds the entries, most recently used first.
+	recency *list.List
+	// epoch counts invalidations, so a read that raced a write does not
+	// put what it read into the cache.
+	epoch uint64
+
+	hits, misses, evictions atomic.Uint64
+}
+
+var _ port.ProductRepository = (*ProductRepository)(nil)
+
+// Option configures a ProductRepository.
+type Option func(*ProductRepository)
+
+// WithClock sets the time source used for expiry.
+func WithClock(now func() time.Time) Option {
+	return func(r *ProductRepository) {
+		r.now = now
+	}
+}
+
+// NewProductRepository caches up to capacity products of inner for ttl
+// each.
+func NewProductRepository(inner port.ProductRepository, capacity int, ttl time.Duration, opts ...Option) *ProductRepository {
+	r := &ProductRepository{
+		ProductRepository: inner,
+		capacity:          max(capacity, 1),
+		ttl:               ttl,
+		now:               time.Now,
+		entries:           map[domain.ProductID]*list.Element{},
+		recency:           list.New(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// FindProductByID serves the product from the cache when it holds a fresh
+// copy and reads it through otherwise. Errors, not found included, are not
+// cached. Reads that ask for domain.PrimaryRead skip the cache.
+func (r *ProductRepository) FindProductByID(ctx context.Context, id domain.ProductID) (*domain.Product, error) {
+	if domain.PrimaryRead(ctx) {
+		return r.ProductRepository.FindProductByID(ctx, id)
+	}
+	if product, ok := r.get(id); ok {
+		r.hits.Add(1)
+		return product, nil
+	}
+	r.misses.Add(1)
+
+	// The shared read must not fail because the caller that happened to
+	// start it gave up, so it runs detached and each caller waits on its
+	// own context.
+	loadCtx := context.WithoutCancel(ctx)
+	result := r.loads.DoChan(string(id), func() (any, error) {
+		r.mu.Lock()
+		epoch := r.epoch
+		r.mu.Unlock()
+
+		product, err := r.ProductRepository.FindProductByID(loadCtx, id)
+		if err != nil {
+			return nil, err
+		}
+		r.put(id, product, epoch)
+		return product, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case loaded := <-result:
+		if loaded.Err != nil {
+			return nil, loaded.Err
+		}
+		product := *loaded.Val.(*domain.Product)
+		return &product, nil
+	}
+}
+
+func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) error {
+	defer r.Invalidate(product.ID)
+	return r.ProductRepository.UpdateProduct(ctx, product)
+}
+
+func (r *ProductRepository) AdjustStock(ctx context.Context, id domain.ProductID, delta int) (*domain.Product, error) {
+	defer r.Invalidate(id)
+	return r.ProductRepository.AdjustStock(ctx, id, delta)
+}
+
+func (r *ProductRepository) DeleteProduct(ctx context.Context, id domain.ProductID, version int64) error {
+	defer r.Invalidate(id)
+	return r.ProductRepository.DeleteProduct(ctx, id, version)
+}
+
+func (r *ProductRepository) RestoreProduct(ctx context.Context, id domain.ProductID) error {
+	defer r.Invalidate(id)
+	return r.ProductRepository.RestoreProduct(ctx, id)
+}
+
+func (r *ProductRepository) PurgeProduct(ctx context.Context, id domain.ProductID) error {
+	defer r.Invalidate(id)
+	return r.ProductRepository.PurgeProduct(ctx, id)
+}
+
+func (r *ProductRepository) UpdateProducts(ctx context.Context, products []*domain.Product) error {
+	ids := make([]domain.ProductID, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	defer r.Invalidate(ids...)
+	return r.ProductRepository.UpdateProducts(ctx, products)
+}
+
+func (r *ProductRepository) DeleteProducts(ctx context.Context, items []port.BulkDelete) error {
+	ids := make([]domain.ProductID, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	defer r.Invalidate(ids...)
+	return r.ProductRepository.DeleteProducts(ctx, items)
+}
+
+// Invalidate drops the given products from the cache. Writes through the
+// repository call it themselves, after the write and whether or not it
+// succeeded; writes that bypass it, such as reservations, must call it.
+func (r *ProductRepository) Invalidate(ids ...domain.ProductID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.epoch++
+	for _, id := range ids {
+		// Readers from now on must not join a read that started before
+		// the write.
+		r.loads.Forget(string(id))
+		if element, ok := r.entries[id]; ok {
+			r.remove(element)
+		}
+	}
+}
+
+// Stats returns the cache counters and current size.
+func (r *ProductRepository) Stats() Stats {
+	r.mu.Lock()
+	size := r.recency.Len()
+	r.mu.Unlock()
+
+	return Stats{
+		Hits:      r.hits.Load(),
+		Misses:    r.misses.Load(),
+		Evictions: r.evictions.Load(),
+		Size:      size,
+	}
+}
+
+// get returns a copy of the cached product, dropping it when expired.
+func (r *ProductRepository) get(id domain.ProductID) (*domain.Product, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.entries[id]
+	if !ok {
+		return nil, false
+	}
+	cached := element.Value.(*entry)
+	if !r.now().Before(cached.expiresAt) {
+		r.remove(element)
+		return nil, false
+	}
+	r.recency.MoveToFront(element)
+	product := cached.product
+	return &product, true
+}
+
+// put caches a copy of product unless an invalidation happened since
+// epoch, evicting the least recently used entry when full.
+func (r *ProductRepository) put(id domain.ProductID, product *domain.Product, epoch uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.epoch != epoch {
+		return
+	}
+	if element, ok := r.entries[id]; ok {
+		r.remove(element)
+	}
+	for r.recency.Len() >= r.capacity {
+		r.remove(r.recency.Back())
+		r.evictions.Add(1)
+	}
+	r.entries[id] = r.recency.PushFront(&entry{
+		id:        id,
+		product:   *product,
+		expiresAt: r.now().Add(r.ttl),
+	})
+}
+
+func (r *ProductRepository) remove(element *list.Element) {
+	r.recency.Remove(element)
+	delete(r.entries, element.Value.(*entry).id)
+}
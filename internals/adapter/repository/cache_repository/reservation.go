@@ -0,0 +1,33 @@
+package cache_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"time"
+)
+
+// reservations invalidates the products whose stock a reservation moves.
+type reservations struct {
+	port.ReservationRepository
+	cache *ProductRepository
+}
+
+// Reservations wraps a reservation repository of the same backend so that
+// the stock it holds and gives back is not served stale from the cache.
+func (r *ProductRepository) Reservations(inner port.ReservationRepository) port.ReservationRepository {
+	return &reservations{ReservationRepository: inner, cache: r}
+}
+
+func (r *reservations) CreateReservation(ctx context.Context, reservation *domain.Reservation) error {
+	defer r.cache.Invalidate(reservation.ProductID)
+	return r.ReservationRepository.CreateReservation(ctx, reservation)
+}
+
+func (r *reservations) SettleReservation(ctx context.Context, id string, status domain.ReservationStatus, at time.Time) (*domain.Reservation, error) {
+	reservation, err := r.ReservationRepository.SettleReservation(ctx, id, status, at)
+	if reservation != nil {
+		r.cache.Invalidate(reservation.ProductID)
+	}
+	return reservation, err
+}
@@ -0,0 +1,57 @@
+package memory_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"strconv"
+	"sync"
+)
+
+// AuditRepository keeps the product change history in process memory.
+type AuditRepository struct {
+	mu      sync.RWMutex
+	entries map[int64][]domain.AuditEntry
+	nextID  int64
+}
+
+var _ port.AuditRepository = (*AuditRepository)(nil)
+
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{
+		entries: make(map[int64][]domain.AuditEntry),
+		nextID:  1,
+	}
+}
+
+func (r *AuditRepository) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	productID, err := parseID(entry.ProductID)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.ID = strconv.FormatInt(r.nextID, 10)
+	r.nextID++
+	r.entries[productID] = append(r.entries[productID], *entry)
+	return nil
+}
+
+func (r *AuditRepository) ListAudit(ctx context.Context, productID domain.ProductID) ([]*domain.AuditEntry, error) {
+	id, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]*domain.AuditEntry, len(r.entries[id]))
+	for i := range r.entries[id] {
+		entry := r.entries[id][i]
+		entries[i] = &entry
+	}
+	return entries, nil
+}
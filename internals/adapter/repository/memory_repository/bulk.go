@@ -0,0 +1,109 @@
+package memory_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"time"
+)
+
+func (r *ProductRepository) SaveProducts(ctx context.Context, products []*domain.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, product := range products {
+		id := r.nextID
+		r.nextID++
+
+		product.ID = formatID(id)
+		product.Version = 1
+		product.Reserved = 0
+		r.products[id] = *product
+		r.record(ctx, domain.ProductCreated, product.ID, product)
+	}
+	return nil
+}
+
+// UpdateProducts checks every product before changing any, so a failure
+// leaves the repository untouched.
+func (r *ProductRepository) UpdateProducts(ctx context.Context, products []*domain.Product) error {
+	ids := make([]int64, len(products))
+	for i, product := range products {
+		id, err := parseID(product.ID)
+		if err != nil {
+			return &domain.BulkItemError{Index: i, Err: err}
+		}
+		ids[i] = id
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Later items see the versions written by earlier ones, as they would
+	// in a transaction.
+	versions := make(map[int64]int64)
+	for i, product := range products {
+		current, ok := r.products[ids[i]]
+		if !ok || current.DeletedAt != nil {
+			return &domain.BulkItemError{Index: i, Err: domain.ErrNotFound}
+		}
+		version, seen := versions[ids[i]]
+		if !seen {
+			version = current.Version
+		}
+		if product.Version != 0 && product.Version != version {
+			return &domain.BulkItemError{Index: i, Err: domain.ErrVersionMismatch}
+		}
+		versions[ids[i]] = version + 1
+	}
+
+	for i, product := range products {
+		current := r.products[ids[i]]
+		product.Version = current.Version + 1
+		product.Reserved = current.Reserved
+		product.DeletedAt = nil
+		updated := *product
+		updated.ID = formatID(ids[i])
+		r.products[ids[i]] = updated
+		r.record(ctx, domain.ProductUpdated, updated.ID, &updated)
+	}
+	return nil
+}
+
+// DeleteProducts checks every product before deleting any, so a failure
+// leaves the repository untouched.
+func (r *ProductRepository) DeleteProducts(ctx context.Context, items []port.BulkDelete) error {
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		id, err := parseID(item.ID)
+		if err != nil {
+			return &domain.BulkItemError{Index: i, Err: err}
+		}
+		ids[i] = id
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := make(map[int64]bool)
+	for i, id := range ids {
+		current, ok := r.products[id]
+		if !ok || current.DeletedAt != nil || deleted[id] {
+			return &domain.BulkItemError{Index: i, Err: domain.ErrNotFound}
+		}
+		if items[i].Version != 0 && items[i].Version != current.Version {
+			return &domain.BulkItemError{Index: i, Err: domain.ErrVersionMismatch}
+		}
+		deleted[id] = true
+	}
+
+	deletedAt := time.Now().UTC()
+	for i, id := range ids {
+		current := r.products[id]
+		current.DeletedAt = &deletedAt
+		current.Version++
+		r.products[id] = current
+		r.record(ctx, domain.ProductDeleted, items[i].ID, nil)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package memory_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"strconv"
+)
+
+type outboxEntry struct {
+	event     domain.ProductEvent
+	published bool
+}
+
+// EnableOutbox makes every write record a domain.ProductEvent under the
+// same lock as the write. Call it before the repository is shared.
+func (r *ProductRepository) EnableOutbox() {
+	r.outbox = true
+}
+
+// record appends an event for a write. The caller holds r.mu.
+func (r *ProductRepository) record(ctx context.Context, eventType domain.EventType, productID domain.ProductID, product *domain.Product) {
+	if !r.outbox {
+		return
+	}
+
+	r.nextEventID++
+	event := domain.NewProductEvent(ctx, eventType, productID, product)
+	event.ID = strconv.FormatInt(r.nextEventID, 10)
+	r.events = append(r.events, outboxEntry{event: *event})
+}
+
+// Outbox reads the events recorded by a memory ProductRepository.
+type Outbox struct {
+	repository *ProductRepository
+}
+
+var _ port.OutboxRepository = (*Outbox)(nil)
+
+// Outbox returns the reader for the events recorded by the repository.
+func (r *ProductRepository) Outbox() *Outbox {
+	return &Outbox{repository: r}
+}
+
+func (o *Outbox) PendingEvents(ctx context.Context, limit int) ([]*domain.ProductEvent, error) {
+	o.repository.mu.RLock()
+	defer o.repository.mu.RUnlock()
+
+	events := []*domain.ProductEvent{}
+	for _, entry := range o.repository.events {
+		if len(events) == limit {
+			break
+		}
+		if !entry.published {
+			event := entry.event
+			events = append(events, &event)
+		}
+	}
+	return events, nil
+}
+
+func (o *Outbox) MarkPublished(ctx context.Context, ids []string) error {
+	published := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		published[id] = true
+	}
+
+	o.repository.mu.Lock()
+	defer o.repository.mu.Unlock()
+
+	events := o.repository.events
+	for i := range events {
+		if published[events[i].event.ID] {
+			events[i].published = true
+		}
+	}
+
+	// Forget the delivered prefix so the log does not grow without bound
+	for len(events) > 0 && events[0].published {
+		events = events[1:]
+	}
+	o.repository.events = events
+	return nil
+}
@@ -0,0 +1,379 @@
+package memory_repository
+
+import (
+	"cmp"
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ProductRepository keeps products in process memory. It is safe for
+// concurrent use and is meant for local runs and tests.
+type ProductRepository struct {
+	mu       sync.RWMutex
+	products map[int64]domain.Product
+	nextID   int64
+
+	outbox      bool
+	events      []outboxEntry
+	nextEventID int64
+
+	reservations map[string]domain.Reservation
+}
+
+var _ port.ProductRepository = (*ProductRepository)(nil)
+
+func NewProductRepository() *ProductRepository {
+	return &ProductRepository{
+		products:     make(map[int64]domain.Product),
+		nextID:       1,
+		reservations: make(map[string]domain.Reservation),
+	}
+}
+
+func (r *ProductRepository) SaveProduct(ctx context.Context, product *domain.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := r.nextID
+	r.nextID++
+
+	product.ID = formatID(id)
+	product.Version = 1
+	product.Reserved = 0
+	r.products[id] = *product
+	r.record(ctx, domain.ProductCreated, product.ID, product)
+	return nil
+}
+
+func (r *ProductRepository) FindProductByID(ctx context.Context, productID domain.ProductID) (*domain.Product, error) {
+	id, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.products[id]
+	if !ok || product.DeletedAt != nil {
+		return nil, domain.ErrNotFound
+	}
+	return &product, nil
+}
+
+func (r *ProductRepository) GetAllProducts(ctx context.Context) ([]*domain.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]int64, 0, len(r.products))
+	for id, product := range r.products {
+		if product.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	products := make([]*domain.Product, 0, len(ids))
+	for _, id := range ids {
+		product := r.products[id]
+		products = append(products, &product)
+	}
+
+	return products, nil
+}
+
+// StreamProducts works on a snapshot so that fn runs without the lock
+// held and may itself write to the repository.
+func (r *ProductRepository) StreamProducts(ctx context.Context, fn func(*domain.Product) error) error {
+	products, err := r.GetAllProducts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ProductRepository) ListProducts(ctx context.Context, query port.ProductQuery) (*port.Page, error) {
+	cursor, err := query.Decode()
+	if err != nil {
+		return nil, err
+	}
+	keys := query.OrderKeys()
+
+	var after *domain.Product
+	if !cursor.IsZero() {
+		if _, err := parseID(cursor.AfterID); err != nil {
+			return nil, err
+		}
+		after = &domain.Product{
+			ID:          cursor.AfterID,
+			ProductName: cursor.Name,
+			Price:       cursor.Price,
+			Stock:       cursor.Stock,
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var products []*domain.Product
+	for _, product := range r.products {
+		product := product
+		if !matches(&product, query.Filter) {
+			continue
+		}
+		if after != nil && compare(&product, after, keys) <= 0 {
+			continue
+		}
+		products = append(products, &product)
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return compare(products[i], products[j], keys) < 0
+	})
+	if len(products) > query.Limit+1 {
+		products = products[:query.Limit+1]
+	}
+
+	return query.NewPage(products), nil
+}
+
+// SearchProducts scores each product by the share of its name words that
+// match a query term, best match first.
+func (r *ProductRepository) SearchProducts(ctx context.Context, query string, limit int) ([]*domain.Product, error) {
+	terms := make(map[string]bool)
+	for _, word := range words(query) {
+		terms[word] = true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type hit struct {
+		product *domain.Product
+		score   float64
+	}
+	var hits []hit
+	for _, product := range r.products {
+		product := product
+		if product.DeletedAt != nil {
+			continue
+		}
+		nameWords := words(product.ProductName)
+
+		matched := 0
+		for _, word := range nameWords {
+			if terms[word] {
+				matched++
+			}
+		}
+		if matched > 0 {
+			hits = append(hits, hit{product: &product, score: float64(matched) / float64(len(nameWords))})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return compare(hits[i].product, hits[j].product, []port.SortKey{{Field: port.SortByID}}) < 0
+	})
+
+	products := make([]*domain.Product, 0, min(limit, len(hits)))
+	for _, hit := range hits {
+		if len(products) == limit {
+			break
+		}
+		products = append(products, hit.product)
+	}
+	return products, nil
+}
+
+func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) error {
+	id, err := parseID(product.ID)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.products[id]
+	if !ok || current.DeletedAt != nil {
+		return domain.ErrNotFound
+	}
+	if product.Version != 0 && product.Version != current.Version {
+		return domain.ErrVersionMismatch
+	}
+
+	product.Version = current.Version + 1
+	product.Reserved = current.Reserved
+	product.DeletedAt = nil
+	updated := *product
+	updated.ID = formatID(id)
+	r.products[id] = updated
+	r.record(ctx, domain.ProductUpdated, updated.ID, &updated)
+	return nil
+}
+
+func (r *ProductRepository) AdjustStock(ctx context.Context, productID domain.ProductID, delta int) (*domain.Product, error) {
+	id, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.products[id]
+	if !ok || current.DeletedAt != nil {
+		return nil, domain.ErrNotFound
+	}
+	if current.Stock+delta < 0 {
+		return nil, domain.ErrInsufficientStock
+	}
+
+	current.Stock += delta
+	current.Version++
+	r.products[id] = current
+	r.record(ctx, domain.ProductUpdated, productID, &current)
+	return &current, nil
+}
+
+func (r *ProductRepository) DeleteProduct(ctx context.Context, productID domain.ProductID, version int64) error {
+	id, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.products[id]
+	if !ok || current.DeletedAt != nil {
+		return domain.ErrNotFound
+	}
+	if version != 0 && version != current.Version {
+		return domain.ErrVersionMismatch
+	}
+
+	deletedAt := time.Now().UTC()
+	current.DeletedAt = &deletedAt
+	current.Version++
+	r.products[id] = current
+	r.record(ctx, domain.ProductDeleted, productID, nil)
+	return nil
+}
+
+func (r *ProductRepository) RestoreProduct(ctx context.Context, productID domain.ProductID) error {
+	id, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.products[id]
+	if !ok || current.DeletedAt == nil {
+		return domain.ErrNotFound
+	}
+
+	current.DeletedAt = nil
+	current.Version++
+	r.products[id] = current
+	r.record(ctx, domain.ProductRestored, productID, nil)
+	return nil
+}
+
+func (r *ProductRepository) PurgeProduct(ctx context.Context, productID domain.ProductID) error {
+	id, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[id]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(r.products, id)
+	r.record(ctx, domain.ProductPurged, productID, nil)
+	return nil
+}
+
+func matches(product *domain.Product, filter port.ProductFilter) bool {
+	if filter.Deleted != (product.DeletedAt != nil) {
+		return false
+	}
+	if filter.PriceMin != nil && product.Price < *filter.PriceMin {
+		return false
+	}
+	if filter.PriceMax != nil && product.Price > *filter.PriceMax {
+		return false
+	}
+	if filter.StockLT != nil && product.Stock >= *filter.StockLT {
+		return false
+	}
+	if filter.NameContains != "" &&
+		!strings.Contains(strings.ToLower(product.ProductName), strings.ToLower(filter.NameContains)) {
+		return false
+	}
+	return true
+}
+
+// compare orders two products by keys, returning -1, 0 or +1.
+func compare(a, b *domain.Product, keys []port.SortKey) int {
+	for _, key := range keys {
+		var c int
+		switch key.Field {
+		case port.SortByName:
+			c = strings.Compare(a.ProductName, b.ProductName)
+		case port.SortByPrice:
+			c = cmp.Compare(a.Price, b.Price)
+		case port.SortByStock:
+			c = cmp.Compare(a.Stock, b.Stock)
+		default:
+			idA, _ := parseID(a.ID)
+			idB, _ := parseID(b.ID)
+			c = cmp.Compare(idA, idB)
+		}
+		if key.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// words splits text into lower-cased words for search.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// parseID maps a domain ID onto the integer keys handed out by SaveProduct.
+func parseID(id domain.ProductID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, domain.ErrInvalidProductID
+	}
+	return n, nil
+}
+
+func formatID(id int64) domain.ProductID {
+	return domain.ProductID(strconv.FormatInt(id, 10))
+}
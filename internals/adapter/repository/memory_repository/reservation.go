@@ -0,0 +1,125 @@
+package memory_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"sort"
+	"time"
+)
+
+// SettledRetention is how long a settled reservation stays findable before
+// the expiry scan of the sweeper drops it, so the map does not grow with
+// every reservation ever made.
+const SettledRetention = 24 * time.Hour
+
+// Reservations holds stock for a memory ProductRepository, under the same
+// lock as the products.
+type Reservations struct {
+	repository *ProductRepository
+}
+
+var _ port.ReservationRepository = (*Reservations)(nil)
+
+// Reservations returns the stock reservations kept alongside the products.
+func (r *ProductRepository) Reservations() *Reservations {
+	return &Reservations{repository: r}
+}
+
+func (s *Reservations) CreateReservation(ctx context.Context, reservation *domain.Reservation) error {
+	id, err := parseID(reservation.ProductID)
+	if err != nil {
+		return err
+	}
+
+	r := s.repository
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, ok := r.products[id]
+	if !ok || product.DeletedAt != nil {
+		return domain.ErrNotFound
+	}
+	if product.Stock < reservation.Quantity {
+		return domain.ErrInsufficientStock
+	}
+
+	product.Stock -= reservation.Quantity
+	product.Reserved += reservation.Quantity
+	product.Version++
+	r.products[id] = product
+	r.reservations[reservation.ID] = *reservation
+	r.record(ctx, domain.ProductUpdated, reservation.ProductID, &product)
+	return nil
+}
+
+func (s *Reservations) FindReservation(ctx context.Context, reservationID string) (*domain.Reservation, error) {
+	r := s.repository
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reservation, ok := r.reservations[reservationID]
+	if !ok {
+		return nil, domain.ErrReservationNotFound
+	}
+	return &reservation, nil
+}
+
+func (s *Reservations) SettleReservation(ctx context.Context, reservationID string, status domain.ReservationStatus, at time.Time) (*domain.Reservation, error) {
+	r := s.repository
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reservation, ok := r.reservations[reservationID]
+	if !ok {
+		return nil, domain.ErrReservationNotFound
+	}
+	if reservation.Status != domain.ReservationHeld {
+		return nil, domain.ErrReservationNotHeld
+	}
+
+	settledAt := at.UTC()
+	reservation.Status = status
+	reservation.SettledAt = &settledAt
+	r.reservations[reservationID] = reservation
+
+	// A purged product has nothing left to give the stock back to.
+	id, _ := parseID(reservation.ProductID)
+	if product, ok := r.products[id]; ok {
+		if status != domain.ReservationCommitted {
+			product.Stock += reservation.Quantity
+		}
+		product.Reserved -= reservation.Quantity
+		product.Version++
+		r.products[id] = product
+		r.record(ctx, domain.ProductUpdated, reservation.ProductID, &product)
+	}
+	return &reservation, nil
+}
+
+// ExpiredReservations also drops the reservations settled more than
+// SettledRetention before now.
+func (s *Reservations) ExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*domain.Reservation, error) {
+	r := s.repository
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reservations := []*domain.Reservation{}
+	for id, reservation := range r.reservations {
+		reservation := reservation
+		if reservation.SettledAt != nil && reservation.SettledAt.Before(now.Add(-SettledRetention)) {
+			delete(r.reservations, id)
+			continue
+		}
+		if reservation.Status == domain.ReservationHeld && reservation.ExpiresAt.Before(now) {
+			reservations = append(reservations, &reservation)
+		}
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		return reservations[i].ExpiresAt.Before(reservations[j].ExpiresAt)
+	})
+	if len(reservations) > limit {
+		reservations = reservations[:limit]
+	}
+	return reservations, nil
+}
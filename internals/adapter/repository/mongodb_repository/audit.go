@@ -0,0 +1,108 @@
+package mongodb_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository stores the product change history in a collection
+// named after the product collection with an "_audit" suffix.
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+var _ port.AuditRepository = (*AuditRepository)(nil)
+
+// auditDocument is the stored shape of a domain.AuditEntry.
+type auditDocument struct {
+	ID        primitive.ObjectID            `bson:"_id,omitempty"`
+	ProductID primitive.ObjectID            `bson:"product_id"`
+	Action    string                        `bson:"action"`
+	Actor     string                        `bson:"actor"`
+	At        time.Time                     `bson:"at"`
+	Reason    string                        `bson:"reason,omitempty"`
+	Changes   map[string]domain.FieldChange `bson:"changes"`
+}
+
+// AuditRepository returns an audit repository sharing the product
+// repository's client.
+func (r *ProductRepository) AuditRepository() *AuditRepository {
+	return &AuditRepository{
+		collection: r.client.Database(r.database).Collection(r.collection + "_audit"),
+	}
+}
+
+// EnsureIndexes creates the index behind ListAudit if it is missing.
+func (r *AuditRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "product_id", Value: 1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetName("product_id_id"),
+	})
+	return err
+}
+
+// RecordAudit inserts the entry. ObjectIDs grow with insertion time, so
+// _id order is recording order.
+func (r *AuditRepository) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	productID, err := parseID(entry.ProductID)
+	if err != nil {
+		return err
+	}
+
+	document := auditDocument{
+		ID:        primitive.NewObjectID(),
+		ProductID: productID,
+		Action:    string(entry.Action),
+		Actor:     entry.Actor,
+		At:        entry.At,
+		Reason:    entry.Reason,
+		Changes:   entry.Changes,
+	}
+	if _, err := r.collection.InsertOne(ctx, document); err != nil {
+		return err
+	}
+	entry.ID = document.ID.Hex()
+	return nil
+}
+
+func (r *AuditRepository) ListAudit(ctx context.Context, productID domain.ProductID) ([]*domain.AuditEntry, error) {
+	objectID, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"product_id": objectID},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []*domain.AuditEntry{}
+	for cursor.Next(ctx) {
+		var document auditDocument
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &domain.AuditEntry{
+			ID:        document.ID.Hex(),
+			ProductID: productID,
+			Action:    domain.AuditAction(document.Action),
+			Actor:     document.Actor,
+			At:        document.At,
+			Reason:    document.Reason,
+			Changes:   document.Changes,
+		})
+	}
+
+	return entries, cursor.Err()
+}
@@ -0,0 +1,119 @@
+package mongodb_repository
+
+import (
+	"context"
+	"errors"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SaveProducts inserts the products with one InsertMany. Like every bulk
+// write it runs in a transaction, so it needs MongoDB to run as a replica
+// set, unless the context allows partial writes and there is no outbox to
+// write to: then the ordered insert stops at the first failing product.
+func (r *ProductRepository) SaveProducts(ctx context.Context, products []*domain.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	if !r.outbox && domain.PartialWrites(ctx) {
+		return r.insertProducts(ctx, products)
+	}
+
+	return r.transaction(ctx, func(ctx context.Context) ([]*domain.ProductEvent, error) {
+		if err := r.insertProducts(ctx, products); err != nil {
+			return nil, err
+		}
+		events := make([]*domain.ProductEvent, len(products))
+		for i, product := range products {
+			events[i] = domain.NewProductEvent(ctx, domain.ProductCreated, product.ID, product)
+		}
+		return events, nil
+	})
+}
+
+// insertProducts inserts the products in order and assigns the IDs of the
+// ones that were saved. A failing product is returned as a
+// *domain.BulkItemError; the products before it are saved.
+func (r *ProductRepository) insertProducts(ctx context.Context, products []*domain.Product) error {
+	coll := r.client.Database(r.database).Collection(r.collection)
+
+	documents := make([]any, len(products))
+	ids := make([]primitive.ObjectID, len(products))
+	for i, product := range products {
+		document := toDocument(product)
+		document.ID = primitive.NewObjectID()
+		document.Version = 1
+		documents[i] = document
+		ids[i] = document.ID
+	}
+
+	saved := len(products)
+	_, err := coll.InsertMany(ctx, documents, options.InsertMany().SetOrdered(true))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 {
+		saved = bulkErr.WriteErrors[0].Index
+		err = &domain.BulkItemError{Index: saved, Err: translateError(err)}
+	} else if err != nil {
+		return translateError(err)
+	}
+
+	for i, product := range products[:saved] {
+		product.ID = formatID(ids[i])
+		product.Version = 1
+		product.Reserved = 0
+	}
+	return err
+}
+
+// UpdateProducts runs the conditional update of UpdateProduct for every
+// product in one transaction. Each update reads back the new version, which
+// a BulkWrite could not report per product.
+func (r *ProductRepository) UpdateProducts(ctx context.Context, products []*domain.Product) error {
+	ids := make([]primitive.ObjectID, len(products))
+	for i, product := range products {
+		objectID, err := parseID(product.ID)
+		if err != nil {
+			return &domain.BulkItemError{Index: i, Err: err}
+		}
+		ids[i] = objectID
+	}
+
+	return r.transaction(ctx, func(ctx context.Context) ([]*domain.ProductEvent, error) {
+		events := make([]*domain.ProductEvent, 0, len(products))
+		for i, product := range products {
+			if err := r.updateProduct(ctx, ids[i], product); err != nil {
+				return nil, &domain.BulkItemError{Index: i, Err: err}
+			}
+			events = append(events, domain.NewProductEvent(ctx, domain.ProductUpdated, product.ID, product))
+		}
+		return events, nil
+	})
+}
+
+// DeleteProducts runs the conditional delete of DeleteProduct for every
+// ID in one transaction, so the failing item can be told apart.
+func (r *ProductRepository) DeleteProducts(ctx context.Context, items []port.BulkDelete) error {
+	ids := make([]primitive.ObjectID, len(items))
+	for i, item := range items {
+		objectID, err := parseID(item.ID)
+		if err != nil {
+			return &domain.BulkItemError{Index: i, Err: err}
+		}
+		ids[i] = objectID
+	}
+
+	return r.transaction(ctx, func(ctx context.Context) ([]*domain.ProductEvent, error) {
+		events := make([]*domain.ProductEvent, 0, len(items))
+		for i, item := range items {
+			if err := r.deleteProduct(ctx, ids[i], item.Version); err != nil {
+				return nil, &domain.BulkItemError{Index: i, Err: err}
+			}
+			events = append(events, domain.NewProductEvent(ctx, domain.ProductDeleted, item.ID, nil))
+		}
+		return events, nil
+	})
+}
@@ -0,0 +1,163 @@
+package mongodb_repository
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexSpec declares an index the adapter keeps in place on the product
+// collection.
+type IndexSpec struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+	Sparse bool
+}
+
+// ProductIndexes is the desired index set for the product collection.
+// Anything not listed here, apart from the built-in _id index, is dropped
+// by EnsureIndexes.
+var ProductIndexes = []IndexSpec{
+	{Name: "sku_unique", Keys: bson.D{{Key: "sku", Value: 1}}, Unique: true, Sparse: true},
+	{Name: "productname_text", Keys: bson.D{{Key: "productname", Value: "text"}}},
+	{Name: "price_stock", Keys: bson.D{{Key: "price", Value: 1}, {Key: "stock", Value: 1}}},
+	{Name: "stock_price", Keys: bson.D{{Key: "stock", Value: 1}, {Key: "price", Value: 1}}},
+}
+
+// IndexReport describes what EnsureIndexes changed.
+type IndexReport struct {
+	Created   []string
+	Dropped   []string
+	Unchanged []string
+}
+
+func (r IndexReport) String() string {
+	return fmt.Sprintf("created %v, dropped %v, unchanged %v", r.Created, r.Dropped, r.Unchanged)
+}
+
+// existingIndex is the subset of listIndexes output we reconcile against.
+type existingIndex struct {
+	Name    string `bson:"name"`
+	Key     bson.D `bson:"key"`
+	Unique  bool   `bson:"unique"`
+	Sparse  bool   `bson:"sparse"`
+	Weights bson.M `bson:"weights"`
+}
+
+// EnsureIndexes reconciles the collection indexes with ProductIndexes.
+// Indexes whose definition changed are dropped and rebuilt.
+func (r *ProductRepository) EnsureIndexes(ctx context.Context) (*IndexReport, error) {
+	coll := r.client.Database(r.database).Collection(r.collection)
+
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var existing []existingIndex
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, err
+	}
+
+	report := &IndexReport{}
+	current := make(map[string]existingIndex, len(existing))
+	for _, index := range existing {
+		current[index.Name] = index
+	}
+
+	wanted := make(map[string]bool, len(ProductIndexes))
+	var models []mongo.IndexModel
+	for _, spec := range ProductIndexes {
+		wanted[spec.Name] = true
+
+		index, ok := current[spec.Name]
+		if ok && matchesSpec(index, spec) {
+			report.Unchanged = append(report.Unchanged, spec.Name)
+			continue
+		}
+		if ok {
+			if _, err := coll.Indexes().DropOne(ctx, spec.Name); err != nil {
+				return report, err
+			}
+			report.Dropped = append(report.Dropped, spec.Name)
+		}
+
+		models = append(models, mongo.IndexModel{
+			Keys: spec.Keys,
+			Options: options.Index().
+				SetName(spec.Name).
+				SetUnique(spec.Unique).
+				SetSparse(spec.Sparse),
+		})
+	}
+
+	for _, index := range existing {
+		if index.Name == "_id_" || wanted[index.Name] {
+			continue
+		}
+		if _, err := coll.Indexes().DropOne(ctx, index.Name); err != nil {
+			return report, err
+		}
+		report.Dropped = append(report.Dropped, index.Name)
+	}
+
+	if len(models) > 0 {
+		names, err := coll.Indexes().CreateMany(ctx, models)
+		if err != nil {
+			return report, err
+		}
+		report.Created = append(report.Created, names...)
+	}
+
+	return report, nil
+}
+
+// matchesSpec reports whether an existing index is equivalent to spec.
+// Text indexes are stored under the internal _fts/_ftsx keys, so their
+// fields are compared through the weights document instead.
+func matchesSpec(index existingIndex, spec IndexSpec) bool {
+	if index.Unique != spec.Unique || index.Sparse != spec.Sparse {
+		return false
+	}
+
+	var textFields []string
+	var keys bson.D
+	for _, key := range spec.Keys {
+		if key.Value == "text" {
+			textFields = append(textFields, key.Key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	if len(textFields) > 0 {
+		if len(index.Weights) != len(textFields) {
+			return false
+		}
+		for _, field := range textFields {
+			if _, ok := index.Weights[field]; !ok {
+				return false
+			}
+		}
+		var rest bson.D
+		for _, key := range index.Key {
+			if key.Key != "_fts" && key.Key != "_ftsx" {
+				rest = append(rest, key)
+			}
+		}
+		index.Key = rest
+	}
+
+	if len(index.Key) != len(keys) {
+		return false
+	}
+	for i, key := range keys {
+		if index.Key[i].Key != key.Key || fmt.Sprint(index.Key[i].Value) != fmt.Sprint(key.Value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package mongodb_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// eventDocument is the stored shape of a domain.ProductEvent.
+type eventDocument struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Type        string             `bson:"type"`
+	ProductID   string             `bson:"product_id"`
+	Product     *productDocument   `bson:"product,omitempty"`
+	Actor       string             `bson:"actor"`
+	OccurredAt  time.Time          `bson:"occurred_at"`
+	PublishedAt *time.Time         `bson:"published_at"`
+}
+
+// EnableOutbox makes every write record a domain.ProductEvent in a
+// collection named after the product collection with an "_outbox"
+// suffix, in the same transaction as the write. Transactions need MongoDB
+// to run as a replica set. Call it before the repository is shared.
+func (r *ProductRepository) EnableOutbox() {
+	r.outbox = true
+}
+
+func (r *ProductRepository) outboxCollection() *mongo.Collection {
+	return r.client.Database(r.database).Collection(r.collection + "_outbox")
+}
+
+// write runs fn and, with the outbox enabled, records the event it returns
+// in the same transaction. fn must do all its work through the context it
+// is given, and may be retried on transient transaction errors.
+func (r *ProductRepository) write(ctx context.Context, fn func(ctx context.Context) (*domain.ProductEvent, error)) error {
+	if !r.outbox {
+		_, err := fn(ctx)
+		return err
+	}
+
+	return r.transaction(ctx, func(ctx context.Context) ([]*domain.ProductEvent, error) {
+		event, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []*domain.ProductEvent{event}, nil
+	})
+}
+
+// transaction runs fn in a transaction and, with the outbox enabled,
+// records the events it returns before committing. fn must do all its
+// work through the context it is given, and may be retried on transient
+// transaction errors.
+func (r *ProductRepository) transaction(ctx context.Context, fn func(ctx context.Context) ([]*domain.ProductEvent, error)) error {
+	session, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (any, error) {
+		events, err := fn(ctx)
+		if err != nil || !r.outbox || len(events) == 0 {
+			return nil, err
+		}
+
+		documents := make([]any, len(events))
+		for i, event := range events {
+			documents[i] = toEventDocument(event)
+		}
+		_, err = r.outboxCollection().InsertMany(ctx, documents)
+		return nil, err
+	})
+	return err
+}
+
+func toEventDocument(event *domain.ProductEvent) eventDocument {
+	document := eventDocument{
+		ID:         primitive.NewObjectID(),
+		Type:       string(event.Type),
+		ProductID:  event.ProductID.String(),
+		Actor:      event.Actor,
+		OccurredAt: event.OccurredAt,
+	}
+	if event.Product != nil {
+		product := toDocument(event.Product)
+		product.Version = event.Product.Version
+		product.Reserved = event.Product.Reserved
+		document.Product = &product
+	}
+	return document
+}
+
+// Outbox reads the events recorded by a MongoDB ProductRepository.
+type Outbox struct {
+	collection *mongo.Collection
+}
+
+var _ port.OutboxRepository = (*Outbox)(nil)
+
+// Outbox returns the reader for the events recorded by the repository.
+func (r *ProductRepository) Outbox() *Outbox {
+	return &Outbox{collection: r.outboxCollection()}
+}
+
+// EnsureIndexes creates the index behind PendingEvents if it is missing.
+func (o *Outbox) EnsureIndexes(ctx context.Context) error {
+	_, err := o.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "published_at", Value: 1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetName("published_at_id"),
+	})
+	return err
+}
+
+func (o *Outbox) PendingEvents(ctx context.Context, limit int) ([]*domain.ProductEvent, error) {
+	cursor, err := o.collection.Find(ctx,
+		bson.M{"published_at": nil},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []*domain.ProductEvent{}
+	for cursor.Next(ctx) {
+		var document eventDocument
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		event := &domain.ProductEvent{
+			ID:         document.ID.Hex(),
+			Type:       domain.EventType(document.Type),
+			ProductID:  domain.ProductID(document.ProductID),
+			Actor:      document.Actor,
+			OccurredAt: document.OccurredAt,
+		}
+		if document.Product != nil {
+			event.Product = document.Product.toDomain()
+			event.Product.ID = event.ProductID
+		}
+		events = append(events, event)
+	}
+
+	return events, cursor.Err()
+}
+
+func (o *Outbox) MarkPublished(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	objectIDs := make(bson.A, len(ids))
+	for i, id := range ids {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return domain.NewValidationError("id", "invalid event ID")
+		}
+		objectIDs[i] = objectID
+	}
+
+	_, err := o.collection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": objectIDs}},
+		bson.M{"$set": bson.M{"published_at": time.Now().UTC()}},
+	)
+	return err
+}
@@ -0,0 +1,86 @@
+package mongodb_repository
+
+import (
+	"goproduct/internals/core/product/port"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// documentFields maps sort fields onto productDocument keys.
+var documentFields = map[port.SortField]string{
+	port.SortByID:    "_id",
+	port.SortByName:  "productname",
+	port.SortByPrice: "price",
+	port.SortByStock: "stock",
+}
+
+// filterDocument translates a ProductFilter into a query document.
+func filterDocument(filter port.ProductFilter) bson.M {
+	document := bson.M{"deleted_at": nil}
+	if filter.Deleted {
+		document["deleted_at"] = bson.M{"$ne": nil}
+	}
+
+	price := bson.M{}
+	if filter.PriceMin != nil {
+		price["$gte"] = *filter.PriceMin
+	}
+	if filter.PriceMax != nil {
+		price["$lte"] = *filter.PriceMax
+	}
+	if len(price) > 0 {
+		document["price"] = price
+	}
+
+	if filter.StockLT != nil {
+		document["stock"] = bson.M{"$lt": *filter.StockLT}
+	}
+	if filter.NameContains != "" {
+		document["productname"] = primitive.Regex{
+			Pattern: regexp.QuoteMeta(filter.NameContains),
+			Options: "i",
+		}
+	}
+	return document
+}
+
+// afterDocument is the keyset condition that resumes right after cursor:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+func afterDocument(keys []port.SortKey, cursor port.Cursor, afterID primitive.ObjectID) bson.M {
+	value := func(field port.SortField) any {
+		if field == port.SortByID {
+			return afterID
+		}
+		return cursor.Value(field)
+	}
+
+	alternatives := bson.A{}
+	for i, key := range keys {
+		alternative := bson.M{}
+		for _, previous := range keys[:i] {
+			alternative[documentFields[previous.Field]] = value(previous.Field)
+		}
+		operator := "$gt"
+		if key.Descending {
+			operator = "$lt"
+		}
+		alternative[documentFields[key.Field]] = bson.M{operator: value(key.Field)}
+		alternatives = append(alternatives, alternative)
+	}
+	return bson.M{"$or": alternatives}
+}
+
+// sortDocument translates sort keys into a sort specification.
+func sortDocument(keys []port.SortKey) bson.D {
+	document := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		direction := 1
+		if key.Descending {
+			direction = -1
+		}
+		document = append(document, bson.E{Key: documentFields[key.Field], Value: direction})
+	}
+	return document
+}
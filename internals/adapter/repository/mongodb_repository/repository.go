@@ -2,9 +2,10 @@ package mongodb_repository
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"goproduct/internals/core/product/domain"
 	"goproduct/internals/core/product/port"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -16,10 +17,22 @@ type ProductRepository struct {
 	client     *mongo.Client
 	database   string
 	collection string
+	outbox     bool
 }
 
 var _ port.ProductRepository = (*ProductRepository)(nil)
 
+// productDocument is the stored shape of a domain.Product.
+type productDocument struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	ProductName string             `bson:"productname"`
+	Price       float64            `bson:"price"`
+	Stock       int                `bson:"stock"`
+	Reserved    int                `bson:"reserved"`
+	Version     int64              `bson:"version"`
+	DeletedAt   *time.Time         `bson:"deleted_at,omitempty"`
+}
+
 func NewProductRepository(uri, database, collection string) (*ProductRepository, error) {
 	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
 	if err != nil {
@@ -39,79 +52,402 @@ func NewProductRepository(uri, database, collection string) (*ProductRepository,
 	}, nil
 }
 
-func (r *ProductRepository) SaveProduct(product *domain.Product) error {
+func (r *ProductRepository) SaveProduct(ctx context.Context, product *domain.Product) error {
 	coll := r.client.Database(r.database).Collection(r.collection)
-	_, err := coll.InsertOne(context.Background(), product)
-	return err
+
+	return r.write(ctx, func(ctx context.Context) (*domain.ProductEvent, error) {
+		document := toDocument(product)
+		document.ID = primitive.NewObjectID()
+		document.Version = 1
+
+		_, err := coll.InsertOne(ctx, document)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		product.ID = formatID(document.ID)
+		product.Version = document.Version
+		return domain.NewProductEvent(ctx, domain.ProductCreated, product.ID, product), nil
+	})
 }
 
-func (r *ProductRepository) FindProductByID(id interface{}) (*domain.Product, error) {
+func (r *ProductRepository) FindProductByID(ctx context.Context, id domain.ProductID) (*domain.Product, error) {
 	coll := r.client.Database(r.database).Collection(r.collection)
-	var product domain.Product
 
-	objectID, ok := id.(primitive.ObjectID)
-	if !ok {
-		return nil, errors.New("invalid ID type for MongoDB")
+	objectID, err := parseID(id)
+	if err != nil {
+		return nil, err
 	}
 
-	err := coll.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&product)
+	var document productDocument
+	err = coll.FindOne(ctx, bson.M{"_id": objectID, "deleted_at": nil}).Decode(&document)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, nil // Not found
+			return nil, domain.ErrNotFound
 		}
 		return nil, err
 	}
-	return &product, nil
+	return document.toDomain(), nil
+}
+
+func (r *ProductRepository) GetAllProducts(ctx context.Context) ([]*domain.Product, error) {
+	var products []*domain.Product
+	err := r.StreamProducts(ctx, func(product *domain.Product) error {
+		products = append(products, product)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// StreamProducts decodes one document at a time off the cursor, which
+// fetches further batches as fn consumes the current one.
+func (r *ProductRepository) StreamProducts(ctx context.Context, fn func(*domain.Product) error) error {
+	coll := r.client.Database(r.database).Collection(r.collection)
+	cursor, err := coll.Find(ctx, bson.M{"deleted_at": nil}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document productDocument
+		if err := cursor.Decode(&document); err != nil {
+			return err
+		}
+		if err := fn(document.toDomain()); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
 }
 
-func (r *ProductRepository) GetAllProducts() ([]*domain.Product, error) {
+// ListProducts translates the query into a filter and sort document and
+// resumes after the cursor with an $or keyset condition.
+func (r *ProductRepository) ListProducts(ctx context.Context, query port.ProductQuery) (*port.Page, error) {
 	coll := r.client.Database(r.database).Collection(r.collection)
-	cursor, err := coll.Find(context.Background(), bson.M{})
+
+	cursor, err := query.Decode()
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
+	keys := query.OrderKeys()
 
-	var products []*domain.Product
-	for cursor.Next(context.Background()) {
-		var product domain.Product
-		if err := cursor.Decode(&product); err != nil {
+	filter := filterDocument(query.Filter)
+	if !cursor.IsZero() {
+		afterID, err := parseID(cursor.AfterID)
+		if err != nil {
 			return nil, err
 		}
-		products = append(products, &product)
+		filter = bson.M{"$and": bson.A{filter, afterDocument(keys, cursor, afterID)}}
 	}
 
-	if err := cursor.Err(); err != nil {
+	findOptions := options.Find().
+		SetSort(sortDocument(keys)).
+		SetLimit(int64(query.Limit + 1))
+	results, err := coll.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close(ctx)
+
+	products := make([]*domain.Product, 0, query.Limit)
+	for results.Next(ctx) {
+		var document productDocument
+		if err := results.Decode(&document); err != nil {
+			return nil, err
+		}
+		products = append(products, document.toDomain())
+	}
+	if err := results.Err(); err != nil {
+		return nil, err
+	}
+
+	return query.NewPage(products), nil
+}
+
+// SearchProducts runs a $text query against the productname_text index and
+// orders by textScore.
+func (r *ProductRepository) SearchProducts(ctx context.Context, query string, limit int) ([]*domain.Product, error) {
+	coll := r.client.Database(r.database).Collection(r.collection)
+
+	score := bson.M{"$meta": "textScore"}
+	findOptions := options.Find().
+		SetProjection(bson.M{"score": score, "productname": 1, "price": 1, "stock": 1, "reserved": 1, "version": 1}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+	results, err := coll.Find(ctx, bson.M{"$text": bson.M{"$search": query}, "deleted_at": nil}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close(ctx)
+
+	products := make([]*domain.Product, 0, limit)
+	for results.Next(ctx) {
+		var document productDocument
+		if err := results.Decode(&document); err != nil {
+			return nil, err
+		}
+		products = append(products, document.toDomain())
+	}
+	if err := results.Err(); err != nil {
 		return nil, err
 	}
 
 	return products, nil
 }
 
-func (r *ProductRepository) UpdateProduct(product *domain.Product) error {
+func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) error {
+	objectID, err := parseID(product.ID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(ctx context.Context) (*domain.ProductEvent, error) {
+		if err := r.updateProduct(ctx, objectID, product); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductUpdated, product.ID, product), nil
+	})
+}
+
+// updateProduct replaces the product if product.Version is still current,
+// or unconditionally when it is zero, and stores the new version in
+// product.Version.
+func (r *ProductRepository) updateProduct(ctx context.Context, objectID primitive.ObjectID, product *domain.Product) error {
+	coll := r.client.Database(r.database).Collection(r.collection)
+
+	document := toDocument(product)
+	document.ID = objectID
+
+	// Replace the fields and bump the version in one conditional update.
+	// The pipeline form lets documents without a version count as 1, and
+	// $literal keeps names starting with "$" from reading as field paths.
+	err := coll.FindOneAndUpdate(
+		ctx,
+		versionFilter(objectID, product.Version),
+		bson.A{bson.M{"$set": bson.M{
+			"productname": bson.M{"$literal": document.ProductName},
+			"price":       document.Price,
+			"stock":       document.Stock,
+			"version":     nextVersion,
+		}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if err == mongo.ErrNoDocuments {
+		return r.missingOrStale(ctx, objectID)
+	}
+	if err != nil {
+		return translateError(err)
+	}
+	product.Version = document.Version
+	return nil
+}
+
+// AdjustStock applies delta with a filtered update that only matches while
+// the stock covers a negative delta, so it can never go below zero.
+func (r *ProductRepository) AdjustStock(ctx context.Context, productID domain.ProductID, delta int) (*domain.Product, error) {
+	coll := r.client.Database(r.database).Collection(r.collection)
+
+	objectID, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	var product *domain.Product
+	err = r.write(ctx, func(ctx context.Context) (*domain.ProductEvent, error) {
+		// The pipeline form keeps documents without a version counting as 1,
+		// as in UpdateProduct.
+		var document productDocument
+		err := coll.FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": objectID, "deleted_at": nil, "stock": bson.M{"$gte": -delta}},
+			bson.A{bson.M{"$set": bson.M{
+				"stock":   bson.M{"$add": bson.A{"$stock", delta}},
+				"version": nextVersion,
+			}}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&document)
+		if err == mongo.ErrNoDocuments {
+			count, err := coll.CountDocuments(ctx, bson.M{"_id": objectID, "deleted_at": nil}, options.Count().SetLimit(1))
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return nil, domain.ErrNotFound
+			}
+			return nil, domain.ErrInsufficientStock
+		}
+		if err != nil {
+			return nil, err
+		}
+		product = document.toDomain()
+		return domain.NewProductEvent(ctx, domain.ProductUpdated, productID, product), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r *ProductRepository) DeleteProduct(ctx context.Context, productID domain.ProductID, version int64) error {
+	objectID, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(ctx context.Context) (*domain.ProductEvent, error) {
+		if err := r.deleteProduct(ctx, objectID, version); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductDeleted, productID, nil), nil
+	})
+}
+
+// deleteProduct moves the product to the trash if version is still
+// current, or unconditionally when it is zero.
+func (r *ProductRepository) deleteProduct(ctx context.Context, objectID primitive.ObjectID, version int64) error {
 	coll := r.client.Database(r.database).Collection(r.collection)
-	result, err := coll.ReplaceOne(
-		context.Background(),
-		bson.M{"_id": product.ID},
-		product,
+
+	result, err := coll.UpdateOne(
+		ctx,
+		versionFilter(objectID, version),
+		bson.A{bson.M{"$set": bson.M{
+			"deleted_at": time.Now().UTC(),
+			"version":    nextVersion,
+		}}},
 	)
 	if err != nil {
 		return err
 	}
-	if result.ModifiedCount == 0 {
-		return errors.New("no document was updated")
+	if result.MatchedCount == 0 {
+		return r.missingOrStale(ctx, objectID)
 	}
 	return nil
 }
 
-func (r *ProductRepository) DeleteProduct(productID interface{}) error {
+// RestoreProduct clears the deletion marker, bumping the version like any
+// other write.
+func (r *ProductRepository) RestoreProduct(ctx context.Context, productID domain.ProductID) error {
 	coll := r.client.Database(r.database).Collection(r.collection)
-	result, err := coll.DeleteOne(context.Background(), bson.M{"_id": productID})
+
+	objectID, err := parseID(productID)
 	if err != nil {
 		return err
 	}
-	if result.DeletedCount == 0 {
-		return errors.New("no document was deleted")
+
+	return r.write(ctx, func(ctx context.Context) (*domain.ProductEvent, error) {
+		result, err := coll.UpdateOne(
+			ctx,
+			bson.M{"_id": objectID, "deleted_at": bson.M{"$ne": nil}},
+			bson.A{
+				bson.M{"$set": bson.M{"version": nextVersion}},
+				bson.M{"$unset": "deleted_at"},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, domain.ErrNotFound
+		}
+		return domain.NewProductEvent(ctx, domain.ProductRestored, productID, nil), nil
+	})
+}
+
+func (r *ProductRepository) PurgeProduct(ctx context.Context, productID domain.ProductID) error {
+	coll := r.client.Database(r.database).Collection(r.collection)
+
+	objectID, err := parseID(productID)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	return r.write(ctx, func(ctx context.Context) (*domain.ProductEvent, error) {
+		result, err := coll.DeleteOne(ctx, bson.M{"_id": objectID})
+		if err != nil {
+			return nil, err
+		}
+		if result.DeletedCount == 0 {
+			return nil, domain.ErrNotFound
+		}
+		return domain.NewProductEvent(ctx, domain.ProductPurged, productID, nil), nil
+	})
+}
+
+// nextVersion is the pipeline expression for the incremented version.
+// Documents written before versioning have no version field and count as
+// version 1.
+var nextVersion = bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 1}}, 1}}
+
+// versionFilter matches the live product at the given version, or at any
+// version when it is 0.
+func versionFilter(objectID primitive.ObjectID, version int64) bson.M {
+	filter := bson.M{"_id": objectID, "deleted_at": nil}
+	switch version {
+	case 0:
+	case 1:
+		filter["version"] = bson.M{"$in": bson.A{1, nil}}
+	default:
+		filter["version"] = version
+	}
+	return filter
+}
+
+// missingOrStale explains a conditional write that matched nothing: the
+// product is either gone or carries another version.
+func (r *ProductRepository) missingOrStale(ctx context.Context, objectID primitive.ObjectID) error {
+	coll := r.client.Database(r.database).Collection(r.collection)
+
+	count, err := coll.CountDocuments(ctx, bson.M{"_id": objectID, "deleted_at": nil}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrNotFound
+	}
+	return domain.ErrVersionMismatch
+}
+
+// translateError maps driver errors onto the domain taxonomy.
+func translateError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: %s", domain.ErrConflict, err.Error())
+	}
+	return err
+}
+
+func toDocument(product *domain.Product) productDocument {
+	return productDocument{
+		ProductName: product.ProductName,
+		Price:       product.Price,
+		Stock:       product.Stock,
+	}
+}
+
+func (d productDocument) toDomain() *domain.Product {
+	return &domain.Product{
+		ID:          formatID(d.ID),
+		ProductName: d.ProductName,
+		Price:       d.Price,
+		Stock:       d.Stock,
+		Reserved:    d.Reserved,
+		Version:     max(d.Version, 1),
+		DeletedAt:   d.DeletedAt,
+	}
+}
+
+// parseID maps a domain ID onto the ObjectID used as _id.
+func parseID(id domain.ProductID) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return primitive.NilObjectID, domain.ErrInvalidProductID
+	}
+	return objectID, nil
+}
+
+func formatID(id primitive.ObjectID) domain.ProductID {
+	return domain.ProductID(id.Hex())
 }
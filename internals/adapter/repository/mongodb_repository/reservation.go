@@ -0,0 +1,210 @@
+package mongodb_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reservationDocument is the stored shape of a domain.Reservation.
+type reservationDocument struct {
+	ID        string             `bson:"_id"`
+	ProductID primitive.ObjectID `bson:"product_id"`
+	Quantity  int                `bson:"quantity"`
+	Status    string             `bson:"status"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	SettledAt *time.Time         `bson:"settled_at,omitempty"`
+}
+
+// Reservations holds stock for a MongoDB ProductRepository in a collection
+// named after the product collection with a "_reservations" suffix. Each
+// change runs in a transaction with the stock movement on the product and,
+// with the outbox enabled, the event recording it, so reservations need
+// MongoDB to run as a replica set.
+type Reservations struct {
+	repository *ProductRepository
+}
+
+var _ port.ReservationRepository = (*Reservations)(nil)
+
+// Reservations returns the stock reservations kept alongside the products.
+func (r *ProductRepository) Reservations() *Reservations {
+	return &Reservations{repository: r}
+}
+
+func (s *Reservations) collection() *mongo.Collection {
+	return s.repository.client.Database(s.repository.database).Collection(s.repository.collection + "_reservations")
+}
+
+func (s *Reservations) products() *mongo.Collection {
+	return s.repository.client.Database(s.repository.database).Collection(s.repository.collection)
+}
+
+// EnsureIndexes creates the index behind ExpiredReservations if it is
+// missing.
+func (s *Reservations) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("status_expires_at"),
+	})
+	return err
+}
+
+// CreateReservation only takes the stock when enough is left, in the same
+// conditional update that moves it into the reserved bucket.
+func (s *Reservations) CreateReservation(ctx context.Context, reservation *domain.Reservation) error {
+	objectID, err := parseID(reservation.ProductID)
+	if err != nil {
+		return err
+	}
+
+	return s.repository.transaction(ctx, func(ctx context.Context) ([]*domain.ProductEvent, error) {
+		var product productDocument
+		err := s.products().FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": objectID, "deleted_at": nil, "stock": bson.M{"$gte": reservation.Quantity}},
+			bson.A{bson.M{"$set": bson.M{
+				"stock":    bson.M{"$subtract": bson.A{"$stock", reservation.Quantity}},
+				"reserved": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$reserved", 0}}, reservation.Quantity}},
+				"version":  nextVersion,
+			}}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&product)
+		if err == mongo.ErrNoDocuments {
+			count, err := s.products().CountDocuments(ctx, bson.M{"_id": objectID, "deleted_at": nil}, options.Count().SetLimit(1))
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return nil, domain.ErrNotFound
+			}
+			return nil, domain.ErrInsufficientStock
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = s.collection().InsertOne(ctx, reservationDocument{
+			ID:        reservation.ID,
+			ProductID: objectID,
+			Quantity:  reservation.Quantity,
+			Status:    string(reservation.Status),
+			CreatedAt: reservation.CreatedAt.UTC(),
+			ExpiresAt: reservation.ExpiresAt.UTC(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []*domain.ProductEvent{
+			domain.NewProductEvent(ctx, domain.ProductUpdated, reservation.ProductID, product.toDomain()),
+		}, nil
+	})
+}
+
+func (s *Reservations) FindReservation(ctx context.Context, id string) (*domain.Reservation, error) {
+	var document reservationDocument
+	err := s.collection().FindOne(ctx, bson.M{"_id": id}).Decode(&document)
+	if err == mongo.ErrNoDocuments {
+		return nil, domain.ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return document.toDomain(), nil
+}
+
+// SettleReservation claims the reservation with a conditional update
+// before touching the product, so only one settlement of a hold wins.
+func (s *Reservations) SettleReservation(ctx context.Context, id string, status domain.ReservationStatus, at time.Time) (*domain.Reservation, error) {
+	var reservation *domain.Reservation
+	err := s.repository.transaction(ctx, func(ctx context.Context) ([]*domain.ProductEvent, error) {
+		var document reservationDocument
+		err := s.collection().FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": id, "status": string(domain.ReservationHeld)},
+			bson.M{"$set": bson.M{"status": string(status), "settled_at": at.UTC()}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&document)
+		if err == mongo.ErrNoDocuments {
+			if _, err := s.FindReservation(ctx, id); err != nil {
+				return nil, err
+			}
+			return nil, domain.ErrReservationNotHeld
+		}
+		if err != nil {
+			return nil, err
+		}
+		reservation = document.toDomain()
+
+		restock := document.Quantity
+		if status == domain.ReservationCommitted {
+			restock = 0
+		}
+		var product productDocument
+		err = s.products().FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": document.ProductID},
+			bson.A{bson.M{"$set": bson.M{
+				"stock":    bson.M{"$add": bson.A{"$stock", restock}},
+				"reserved": bson.M{"$subtract": bson.A{bson.M{"$ifNull": bson.A{"$reserved", 0}}, document.Quantity}},
+				"version":  nextVersion,
+			}}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&product)
+		// A purged product has nothing left to give the stock back to.
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []*domain.ProductEvent{
+			domain.NewProductEvent(ctx, domain.ProductUpdated, reservation.ProductID, product.toDomain()),
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (s *Reservations) ExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*domain.Reservation, error) {
+	cursor, err := s.collection().Find(ctx,
+		bson.M{"status": string(domain.ReservationHeld), "expires_at": bson.M{"$lt": now.UTC()}},
+		options.Find().SetSort(bson.D{{Key: "expires_at", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reservations := []*domain.Reservation{}
+	for cursor.Next(ctx) {
+		var document reservationDocument
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, document.toDomain())
+	}
+
+	return reservations, cursor.Err()
+}
+
+func (d reservationDocument) toDomain() *domain.Reservation {
+	return &domain.Reservation{
+		ID:        d.ID,
+		ProductID: formatID(d.ProductID),
+		Quantity:  d.Quantity,
+		Status:    domain.ReservationStatus(d.Status),
+		CreatedAt: d.CreatedAt,
+		ExpiresAt: d.ExpiresAt,
+		SettledAt: d.SettledAt,
+	}
+}
@@ -0,0 +1,95 @@
+package mysql_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"strings"
+)
+
+// bulkInsertRows caps the rows of one multi-row INSERT.
+const bulkInsertRows = 500
+
+// SaveProducts inserts the products with multi-row INSERTs in one
+// transaction. LAST_INSERT_ID() is the ID of the first row of a statement;
+// InnoDB hands the rows of a multi-row insert with known length
+// consecutive IDs, as long as auto_increment_increment is left at 1.
+func (r *ProductRepository) SaveProducts(ctx context.Context, products []*domain.Product) error {
+	return r.transaction(ctx, func(q queryer) ([]*domain.ProductEvent, error) {
+		events := make([]*domain.ProductEvent, 0, len(products))
+		for start := 0; start < len(products); start += bulkInsertRows {
+			chunk := products[start:min(start+bulkInsertRows, len(products))]
+
+			query := "INSERT INTO Product (product_name, price, stock) VALUES " +
+				strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(chunk)), ", ")
+			args := make([]any, 0, 3*len(chunk))
+			for _, product := range chunk {
+				args = append(args, product.ProductName, product.Price, product.Stock)
+			}
+			result, err := q.ExecContext(ctx, query, args...)
+			if err != nil {
+				return nil, translateError(err)
+			}
+			first, err := result.LastInsertId()
+			if err != nil {
+				return nil, err
+			}
+
+			for i, product := range chunk {
+				product.ID = formatID(first + int64(i))
+				product.Version = 1
+				product.Reserved = 0
+				events = append(events, domain.NewProductEvent(ctx, domain.ProductCreated, product.ID, product))
+			}
+		}
+		return events, nil
+	})
+}
+
+// UpdateProducts runs the conditional update of UpdateProduct for every
+// product in one transaction.
+func (r *ProductRepository) UpdateProducts(ctx context.Context, products []*domain.Product) error {
+	ids := make([]int64, len(products))
+	for i, product := range products {
+		id, err := parseID(product.ID)
+		if err != nil {
+			return &domain.BulkItemError{Index: i, Err: err}
+		}
+		ids[i] = id
+	}
+
+	return r.transaction(ctx, func(q queryer) ([]*domain.ProductEvent, error) {
+		events := make([]*domain.ProductEvent, 0, len(products))
+		for i, product := range products {
+			if err := updateProduct(ctx, q, ids[i], product); err != nil {
+				return nil, &domain.BulkItemError{Index: i, Err: err}
+			}
+			events = append(events, domain.NewProductEvent(ctx, domain.ProductUpdated, product.ID, product))
+		}
+		return events, nil
+	})
+}
+
+// DeleteProducts runs the conditional delete of DeleteProduct for every
+// ID in one transaction.
+func (r *ProductRepository) DeleteProducts(ctx context.Context, items []port.BulkDelete) error {
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		id, err := parseID(item.ID)
+		if err != nil {
+			return &domain.BulkItemError{Index: i, Err: err}
+		}
+		ids[i] = id
+	}
+
+	return r.transaction(ctx, func(q queryer) ([]*domain.ProductEvent, error) {
+		events := make([]*domain.ProductEvent, 0, len(items))
+		for i, item := range items {
+			if err := deleteProduct(ctx, q, ids[i], item.Version); err != nil {
+				return nil, &domain.BulkItemError{Index: i, Err: err}
+			}
+			events = append(events, domain.NewProductEvent(ctx, domain.ProductDeleted, item.ID, nil))
+		}
+		return events, nil
+	})
+}
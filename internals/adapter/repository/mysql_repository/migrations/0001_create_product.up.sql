@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS Product (
+	product_id   INT          NOT NULL AUTO_INCREMENT,
+	product_name VARCHAR(255) NOT NULL,
+	price        DOUBLE       NOT NULL DEFAULT 0,
+	stock        INT          NOT NULL DEFAULT 0,
+	PRIMARY KEY (product_id)
+);
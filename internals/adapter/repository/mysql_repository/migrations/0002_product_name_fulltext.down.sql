@@ -0,0 +1 @@
+ALTER TABLE Product DROP INDEX ft_product_name;
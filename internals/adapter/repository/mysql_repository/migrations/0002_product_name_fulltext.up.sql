@@ -0,0 +1 @@
+ALTER TABLE Product ADD FULLTEXT INDEX ft_product_name (product_name);
@@ -0,0 +1 @@
+ALTER TABLE Product DROP COLUMN version;
@@ -0,0 +1 @@
+ALTER TABLE Product ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
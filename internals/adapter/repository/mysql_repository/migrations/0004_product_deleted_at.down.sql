@@ -0,0 +1,3 @@
+ALTER TABLE Product
+	DROP INDEX idx_product_deleted_at,
+	DROP COLUMN deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE Product
+	ADD COLUMN deleted_at DATETIME(6) NULL,
+	ADD INDEX idx_product_deleted_at (deleted_at);
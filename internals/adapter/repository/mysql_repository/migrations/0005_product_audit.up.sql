@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS ProductAudit (
+	audit_id   BIGINT       NOT NULL AUTO_INCREMENT,
+	product_id INT          NOT NULL,
+	action     VARCHAR(16)  NOT NULL,
+	actor      VARCHAR(255) NOT NULL,
+	changed_at DATETIME(6)  NOT NULL,
+	changes    JSON         NOT NULL,
+	PRIMARY KEY (audit_id),
+	INDEX idx_product_audit_product (product_id, audit_id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS ProductOutbox (
+	event_id     BIGINT       NOT NULL AUTO_INCREMENT,
+	event_type   VARCHAR(32)  NOT NULL,
+	product_id   VARCHAR(64)  NOT NULL,
+	actor        VARCHAR(255) NOT NULL,
+	occurred_at  DATETIME(6)  NOT NULL,
+	payload      JSON         NULL,
+	published_at DATETIME(6)  NULL,
+	PRIMARY KEY (event_id),
+	INDEX idx_product_outbox_pending (published_at, event_id)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ProductReservation;
+
+ALTER TABLE Product DROP COLUMN reserved;
@@ -0,0 +1,13 @@
+ALTER TABLE Product ADD COLUMN reserved INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS ProductReservation (
+	reservation_id CHAR(32)    NOT NULL,
+	product_id     INT         NOT NULL,
+	quantity       INT         NOT NULL,
+	status         VARCHAR(16) NOT NULL,
+	created_at     DATETIME(6) NOT NULL,
+	expires_at     DATETIME(6) NOT NULL,
+	settled_at     DATETIME(6) NULL,
+	PRIMARY KEY (reservation_id),
+	INDEX idx_product_reservation_expiry (status, expires_at)
+);
@@ -0,0 +1 @@
+ALTER TABLE ProductAudit DROP COLUMN reason;
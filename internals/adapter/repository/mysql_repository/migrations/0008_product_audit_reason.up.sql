@@ -0,0 +1 @@
+ALTER TABLE ProductAudit ADD COLUMN reason VARCHAR(32) NOT NULL DEFAULT '';
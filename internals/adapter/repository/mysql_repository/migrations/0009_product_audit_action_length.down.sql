@@ -0,0 +1 @@
+ALTER TABLE ProductAudit MODIFY action VARCHAR(16) NOT NULL;
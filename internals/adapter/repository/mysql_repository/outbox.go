@@ -0,0 +1,69 @@
+package mysql_repository
+
+import (
+	"context"
+	"database/sql"
+	"goproduct/internals/adapter/repository/sqloutbox"
+	"goproduct/internals/adapter/repository/sqlquery"
+	"goproduct/internals/core/product/domain"
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// EnableOutbox makes every write record a domain.ProductEvent in the
+// ProductOutbox table, in the same transaction as the write. Call it
+// before the repository is shared.
+func (r *ProductRepository) EnableOutbox() {
+	r.outbox = true
+}
+
+// Outbox returns the reader for the events recorded by the repository.
+func (r *ProductRepository) Outbox() *sqloutbox.Outbox {
+	return sqloutbox.New(r.db, sqlquery.MySQL)
+}
+
+// write runs fn and, with the outbox enabled, records the event it returns
+// in the same transaction. An error from fn rolls the transaction back.
+func (r *ProductRepository) write(ctx context.Context, fn func(q queryer) (*domain.ProductEvent, error)) error {
+	if !r.outbox {
+		_, err := fn(r.db)
+		return err
+	}
+
+	return r.transaction(ctx, func(q queryer) ([]*domain.ProductEvent, error) {
+		event, err := fn(q)
+		if err != nil {
+			return nil, err
+		}
+		return []*domain.ProductEvent{event}, nil
+	})
+}
+
+// transaction runs fn in a transaction and, with the outbox enabled,
+// records the events it returns before committing. An error from fn rolls
+// the transaction back.
+func (r *ProductRepository) transaction(ctx context.Context, fn func(q queryer) ([]*domain.ProductEvent, error)) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	events, err := fn(tx)
+	if err != nil {
+		return err
+	}
+	if r.outbox {
+		for _, event := range events {
+			if err := sqloutbox.Record(ctx, tx, sqlquery.MySQL, event); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
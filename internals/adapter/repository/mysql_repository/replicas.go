@@ -0,0 +1,129 @@
+package mysql_repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"goproduct/internals/core/product/domain"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// replicaCheckTimeout bounds the ping of one replica health check.
+const replicaCheckTimeout = 2 * time.Second
+
+// rowsQueryer runs reads; *sql.DB satisfies it.
+type rowsQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// replicaDB is the connection pool of one replica.
+type replicaDB interface {
+	rowsQueryer
+	PingContext(ctx context.Context) error
+}
+
+type replica struct {
+	db      replicaDB
+	healthy atomic.Bool
+}
+
+// replicaSet spreads reads round-robin over the healthy replicas.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+// pick returns the next healthy replica, or nil when there is none.
+func (s *replicaSet) pick() *replica {
+	n := uint64(len(s.replicas))
+	start := s.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if replica := s.replicas[(start+i)%n]; replica.healthy.Load() {
+			return replica
+		}
+	}
+	return nil
+}
+
+// UseReplicas sends FindProductByID, GetAllProducts, StreamProducts,
+// ListProducts and SearchProducts to the replicas at dsns, unless the
+// context asks for domain.PrimaryRead. Everything else, writes and the
+// reads inside them included, stays on the primary. A replica that cannot
+// be reached now is not an error: it takes reads once CheckReplicas finds
+// it healthy.
+func (r *ProductRepository) UseReplicas(ctx context.Context, dsns ...string) error {
+	set := &replicaSet{}
+	for _, dsn := range dsns {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return err
+		}
+		db, err := sql.Open("mysql", cfg.FormatDSN())
+		if err != nil {
+			return err
+		}
+		set.replicas = append(set.replicas, &replica{db: db})
+	}
+
+	r.replicas = set
+	r.CheckReplicas(ctx)
+	return nil
+}
+
+// CheckReplicas pings every replica and takes the ones that fail out of
+// rotation until a later check succeeds.
+func (r *ProductRepository) CheckReplicas(ctx context.Context) {
+	if r.replicas == nil {
+		return
+	}
+	for _, replica := range r.replicas.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+		replica.healthy.Store(replica.db.PingContext(pingCtx) == nil)
+		cancel()
+	}
+}
+
+// RunReplicaChecks calls CheckReplicas every interval until ctx is done.
+func (r *ProductRepository) RunReplicaChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.CheckReplicas(ctx)
+		}
+	}
+}
+
+// query runs a read on a healthy replica, or on the primary when the
+// context asks for domain.PrimaryRead.
+func (r *ProductRepository) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if r.replicas == nil || domain.PrimaryRead(ctx) {
+		return r.db.QueryContext(ctx, query, args...)
+	}
+	return r.replicas.query(ctx, r.db, query, args...)
+}
+
+// query runs a read on a healthy replica and falls back to primary when
+// there is none or the replica fails, taking that replica out of rotation
+// until the next check. Errors met while iterating the rows are not
+// retried.
+func (s *replicaSet) query(ctx context.Context, primary rowsQueryer, query string, args ...any) (*sql.Rows, error) {
+	replica := s.pick()
+	if replica == nil {
+		return primary.QueryContext(ctx, query, args...)
+	}
+
+	rows, err := replica.db.QueryContext(ctx, query, args...)
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return rows, err
+	}
+	replica.healthy.Store(false)
+	return primary.QueryContext(ctx, query, args...)
+}
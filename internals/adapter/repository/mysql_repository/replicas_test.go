@@ -0,0 +1,125 @@
+package mysql_repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubDB records which pool served a query and fails it when told to.
+type stubDB struct {
+	name    string
+	served  *[]string
+	err     error
+	pingErr error
+}
+
+func (s *stubDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	*s.served = append(*s.served, s.name)
+	return nil, s.err
+}
+
+func (s *stubDB) PingContext(ctx context.Context) error {
+	return s.pingErr
+}
+
+func newStubReplicas(served *[]string, names ...string) (*replicaSet, []*stubDB) {
+	set := &replicaSet{}
+	var dbs []*stubDB
+	for _, name := range names {
+		db := &stubDB{name: name, served: served}
+		replica := &replica{db: db}
+		replica.healthy.Store(true)
+		set.replicas = append(set.replicas, replica)
+		dbs = append(dbs, db)
+	}
+	return set, dbs
+}
+
+func TestReplicaSetPick(t *testing.T) {
+	var served []string
+	set, _ := newStubReplicas(&served, "a", "b", "c")
+
+	picks := map[*replica]int{}
+	for i := 0; i < 9; i++ {
+		picks[set.pick()]++
+	}
+	for _, replica := range set.replicas {
+		assert.Equal(t, 3, picks[replica])
+	}
+
+	set.replicas[1].healthy.Store(false)
+	for i := 0; i < 6; i++ {
+		assert.NotSame(t, set.replicas[1], set.pick())
+	}
+
+	for _, replica := range set.replicas {
+		replica.healthy.Store(false)
+	}
+	assert.Nil(t, set.pick())
+	assert.Nil(t, (&replicaSet{}).pick())
+}
+
+func TestReplicaSetQuery(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads from a replica", func(t *testing.T) {
+		var served []string
+		set, _ := newStubReplicas(&served, "a", "b")
+		primary := &stubDB{name: "primary", served: &served}
+
+		for i := 0; i < 4; i++ {
+			_, err := set.query(ctx, primary, "SELECT 1")
+			require.NoError(t, err)
+		}
+		assert.ElementsMatch(t, []string{"a", "a", "b", "b"}, served)
+	})
+
+	t.Run("falls back to the primary", func(t *testing.T) {
+		var served []string
+		set, dbs := newStubReplicas(&served, "a")
+		dbs[0].err = errors.New("connection refused")
+		primary := &stubDB{name: "primary", served: &served}
+
+		_, err := set.query(ctx, primary, "SELECT 1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "primary"}, served)
+		assert.False(t, set.replicas[0].healthy.Load())
+
+		// The failed replica stays out of rotation
+		_, err = set.query(ctx, primary, "SELECT 1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "primary", "primary"}, served)
+	})
+
+	t.Run("does not retry a cancelled read", func(t *testing.T) {
+		var served []string
+		set, dbs := newStubReplicas(&served, "a")
+		dbs[0].err = context.Canceled
+		primary := &stubDB{name: "primary", served: &served}
+
+		_, err := set.query(ctx, primary, "SELECT 1")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, []string{"a"}, served)
+		assert.True(t, set.replicas[0].healthy.Load())
+	})
+}
+
+func TestCheckReplicas(t *testing.T) {
+	var served []string
+	set, dbs := newStubReplicas(&served, "a", "b")
+	repo := &ProductRepository{replicas: set}
+
+	dbs[0].pingErr = errors.New("connection refused")
+	repo.CheckReplicas(context.Background())
+	assert.False(t, set.replicas[0].healthy.Load())
+	assert.True(t, set.replicas[1].healthy.Load())
+
+	dbs[0].pingErr = nil
+	repo.CheckReplicas(context.Background())
+	assert.True(t, set.replicas[0].healthy.Load())
+}
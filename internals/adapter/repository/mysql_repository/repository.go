@@ -1,21 +1,40 @@
 package mysql_repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"goproduct/internals/adapter/repository/sqlquery"
 	"goproduct/internals/core/product/domain"
 	"goproduct/internals/core/product/port"
+	"strconv"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
+// productColumns is the column list scanProduct expects.
+const productColumns = "product_id, product_name, price, stock, reserved, version, deleted_at"
+
 type ProductRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	outbox   bool
+	replicas *replicaSet
 }
 
 var _ port.ProductRepository = (*ProductRepository)(nil)
 
 func NewProductRepository(dsn string) (*ProductRepository, error) {
-	db, err := sql.Open("mysql", dsn)
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Report matched rather than changed rows, so that an update which
+	// leaves a row as it was is not mistaken for a missing product.
+	cfg.ClientFoundRows = true
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
 		return nil, err
 	}
@@ -29,52 +48,374 @@ func NewProductRepository(dsn string) (*ProductRepository, error) {
 	return &ProductRepository{db: db}, nil
 }
 
-func (r *ProductRepository) SaveProduct(product *domain.Product) error {
-	query := "INSERT INTO Product (product_name, price, stock) VALUES (?, ?, ?)"
-	_, err := r.db.Exec(query, product.ProductName, product.Price, product.Stock)
-	return err
+func (r *ProductRepository) SaveProduct(ctx context.Context, product *domain.Product) error {
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		query := "INSERT INTO Product (product_name, price, stock) VALUES (?, ?, ?)"
+		result, err := q.ExecContext(ctx, query, product.ProductName, product.Price, product.Stock)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		product.ID = formatID(id)
+		product.Version = 1
+		product.Reserved = 0
+		return domain.NewProductEvent(ctx, domain.ProductCreated, product.ID, product), nil
+	})
 }
 
-func (r *ProductRepository) FindProductByID(productID interface{}) (*domain.Product, error) {
-	query := "SELECT product_id, product_name, price, stock FROM Product WHERE product_id = ?"
-	var product domain.Product
-	err := r.db.QueryRow(query, productID).Scan(&product.ID, &product.ProductName, &product.Price, &product.Stock)
+func (r *ProductRepository) FindProductByID(ctx context.Context, productID domain.ProductID) (*domain.Product, error) {
+	id, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + productColumns + " FROM Product WHERE product_id = ? AND deleted_at IS NULL"
+	rows, err := r.query(ctx, query, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // Not found
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
 		}
+		return nil, domain.ErrNotFound
+	}
+	return scanProduct(rows)
+}
+func (r *ProductRepository) GetAllProducts(ctx context.Context) ([]*domain.Product, error) {
+	var products []*domain.Product
+	err := r.StreamProducts(ctx, func(product *domain.Product) error {
+		products = append(products, product)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-	return &product, nil
+	return products, nil
+}
+
+// StreamProducts hands each row to fn as it is scanned. The connection is
+// held until the last row, so fn should not block for long.
+func (r *ProductRepository) StreamProducts(ctx context.Context, fn func(*domain.Product) error) error {
+	query := "SELECT " + productColumns + " FROM Product WHERE deleted_at IS NULL ORDER BY product_id"
+	rows, err := r.query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ListProducts runs a keyset query built from the filter and sort,
+// fetching one extra row to find out whether another page follows.
+func (r *ProductRepository) ListProducts(ctx context.Context, query port.ProductQuery) (*port.Page, error) {
+	cursor, err := query.Decode()
+	if err != nil {
+		return nil, err
+	}
+	var afterID int64
+	if !cursor.IsZero() {
+		if afterID, err = parseID(cursor.AfterID); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := query.OrderKeys()
+	builder := sqlquery.NewBuilder(sqlquery.MySQL)
+	builder.Filter(query.Filter)
+	builder.After(keys, cursor, afterID)
+	statement, args := builder.Select(productColumns, "Product", keys, query.Limit+1)
+
+	rows, err := r.query(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]*domain.Product, 0, query.Limit)
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return query.NewPage(products), nil
 }
-func (r *ProductRepository) GetAllProducts() ([]*domain.Product, error) {
-	query := "SELECT product_id, product_name, price, stock FROM Product"
-	rows, err := r.db.Query(query)
+
+// SearchProducts uses the ft_product_name FULLTEXT index in natural
+// language mode, ordered by MATCH relevance.
+func (r *ProductRepository) SearchProducts(ctx context.Context, query string, limit int) ([]*domain.Product, error) {
+	statement := "SELECT " + productColumns + ` FROM Product
+		WHERE MATCH(product_name) AGAINST (? IN NATURAL LANGUAGE MODE) AND deleted_at IS NULL
+		ORDER BY MATCH(product_name) AGAINST (? IN NATURAL LANGUAGE MODE) DESC, product_id
+		LIMIT ?`
+	rows, err := r.query(ctx, statement, query, query, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var products []*domain.Product
+	products := make([]*domain.Product, 0, limit)
 	for rows.Next() {
-		var product domain.Product
-		if err := rows.Scan(&product.ID, &product.ProductName, &product.Price, &product.Stock); err != nil {
+		product, err := scanProduct(rows)
+		if err != nil {
 			return nil, err
 		}
-		products = append(products, &product)
+		products = append(products, product)
 	}
 
-	return products, nil
+	return products, rows.Err()
 }
 
-func (r *ProductRepository) UpdateProduct(product *domain.Product) error {
-	query := "UPDATE Product SET product_name = ?, price = ?, stock = ? WHERE product_id = ?"
-	_, err := r.db.Exec(query, product.ProductName, product.Price, product.Stock, product.ID)
+// UpdateProduct bumps the version in the same statement as the
+// compare-and-swap. LAST_INSERT_ID(expr) makes the new version readable
+// from the result without a second query.
+func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) error {
+	id, err := parseID(product.ID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		if err := updateProduct(ctx, q, id, product); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductUpdated, product.ID, product), nil
+	})
+}
+
+// updateProduct replaces the product with the given ID if product.Version
+// is still current, or unconditionally when it is zero, and stores the new
+// version in product.Version.
+func updateProduct(ctx context.Context, q queryer, id int64, product *domain.Product) error {
+	query := `UPDATE Product SET product_name = ?, price = ?, stock = ?, version = LAST_INSERT_ID(version + 1)
+		WHERE product_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`
+	result, err := q.ExecContext(ctx, query,
+		product.ProductName, product.Price, product.Stock, id, product.Version, product.Version)
+	if err != nil {
+		return translateError(err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return missingOrStale(ctx, q, id)
+	}
+
+	product.Version, err = result.LastInsertId()
 	return err
 }
 
-func (r *ProductRepository) DeleteProduct(productID interface{}) error {
-	query := "DELETE FROM Product WHERE product_id = ?"
-	_, err := r.db.Exec(query, productID)
+// AdjustStock applies delta in a single conditional update that refuses to
+// take the stock below zero, then reads the product back. MySQL has no
+// RETURNING, so both run in a transaction: the update holds the row lock
+// until the read, and a concurrent adjustment cannot slip in between.
+func (r *ProductRepository) AdjustStock(ctx context.Context, productID domain.ProductID, delta int) (*domain.Product, error) {
+	id, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	var product *domain.Product
+	err = r.transaction(ctx, func(q queryer) ([]*domain.ProductEvent, error) {
+		query := `UPDATE Product SET stock = stock + ?, version = version + 1
+			WHERE product_id = ? AND deleted_at IS NULL AND stock + ? >= 0`
+		result, err := q.ExecContext(ctx, query, delta, id, delta)
+		if err != nil {
+			return nil, err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if affected == 0 {
+			return nil, missingOrShort(ctx, q, id)
+		}
+
+		query = "SELECT " + productColumns + " FROM Product WHERE product_id = ?"
+		if product, err = scanProduct(q.QueryRowContext(ctx, query, id)); err != nil {
+			return nil, err
+		}
+		return []*domain.ProductEvent{domain.NewProductEvent(ctx, domain.ProductUpdated, productID, product)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r *ProductRepository) DeleteProduct(ctx context.Context, productID domain.ProductID, version int64) error {
+	id, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		if err := deleteProduct(ctx, q, id, version); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductDeleted, productID, nil), nil
+	})
+}
+
+// deleteProduct moves the product with the given ID to the trash if
+// version is still current, or unconditionally when it is zero.
+func deleteProduct(ctx context.Context, q queryer, id int64, version int64) error {
+	query := `UPDATE Product SET deleted_at = ?, version = version + 1
+		WHERE product_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`
+	result, err := q.ExecContext(ctx, query, time.Now().UTC(), id, version, version)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return missingOrStale(ctx, q, id)
+	}
+	return nil
+}
+
+// RestoreProduct clears the deletion marker, bumping the version like any
+// other write.
+func (r *ProductRepository) RestoreProduct(ctx context.Context, productID domain.ProductID) error {
+	id, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		query := "UPDATE Product SET deleted_at = NULL, version = version + 1 WHERE product_id = ? AND deleted_at IS NOT NULL"
+		result, err := q.ExecContext(ctx, query, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := expectAffected(result); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductRestored, productID, nil), nil
+	})
+}
+
+func (r *ProductRepository) PurgeProduct(ctx context.Context, productID domain.ProductID) error {
+	id, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		query := "DELETE FROM Product WHERE product_id = ?"
+		result, err := q.ExecContext(ctx, query, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := expectAffected(result); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductPurged, productID, nil), nil
+	})
+}
+
+// translateError maps driver errors onto the domain taxonomy.
+func translateError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 { // ER_DUP_ENTRY
+		return fmt.Errorf("%w: %s", domain.ErrConflict, mysqlErr.Message)
+	}
 	return err
 }
+
+// expectAffected turns a statement that matched no rows into ErrNotFound.
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// missingOrStale explains a conditional write that matched no rows: the
+// product is either gone or carries another version.
+func missingOrStale(ctx context.Context, q queryer, id int64) error {
+	exists, err := productExists(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrNotFound
+	}
+	return domain.ErrVersionMismatch
+}
+
+// missingOrShort explains a stock adjustment that matched no rows: the
+// product is either gone or has too little stock.
+func missingOrShort(ctx context.Context, q queryer, id int64) error {
+	exists, err := productExists(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrNotFound
+	}
+	return domain.ErrInsufficientStock
+}
+
+// productExists reports whether a live product has the given ID.
+func productExists(ctx context.Context, q queryer, id int64) (bool, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Product WHERE product_id = ? AND deleted_at IS NULL)", id).Scan(&exists)
+	return exists, err
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProduct(row scanner) (*domain.Product, error) {
+	var id int64
+	var deletedAt sql.NullTime
+	var product domain.Product
+	if err := row.Scan(&id, &product.ProductName, &product.Price, &product.Stock, &product.Reserved, &product.Version, &deletedAt); err != nil {
+		return nil, err
+	}
+	product.ID = formatID(id)
+	if deletedAt.Valid {
+		product.DeletedAt = &deletedAt.Time
+	}
+	return &product, nil
+}
+
+// parseID maps a domain ID onto the auto-increment product_id column.
+func parseID(id domain.ProductID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, domain.ErrInvalidProductID
+	}
+	return n, nil
+}
+
+func formatID(id int64) domain.ProductID {
+	return domain.ProductID(strconv.FormatInt(id, 10))
+}
@@ -0,0 +1,115 @@
+package postgres_repository
+
+import (
+	"context"
+	"fmt"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"slices"
+	"strings"
+)
+
+// bulkInsertRows caps the rows of one multi-row INSERT.
+const bulkInsertRows = 500
+
+// SaveProducts inserts the products with multi-row INSERTs in one
+// transaction. RETURNING makes no promise about row order, but the IDs
+// drawn from a sequence grow with the row order of the statement, so
+// sorting them pairs each ID with its product.
+func (r *ProductRepository) SaveProducts(ctx context.Context, products []*domain.Product) error {
+	return r.transaction(ctx, func(q queryer) ([]*domain.ProductEvent, error) {
+		events := make([]*domain.ProductEvent, 0, len(products))
+		for start := 0; start < len(products); start += bulkInsertRows {
+			chunk := products[start:min(start+bulkInsertRows, len(products))]
+
+			rows := make([]string, len(chunk))
+			args := make([]any, 0, 3*len(chunk))
+			for i, product := range chunk {
+				rows[i] = fmt.Sprintf("($%d, $%d, $%d)", 3*i+1, 3*i+2, 3*i+3)
+				args = append(args, product.ProductName, product.Price, product.Stock)
+			}
+			query := "INSERT INTO Product (product_name, price, stock) VALUES " +
+				strings.Join(rows, ", ") + " RETURNING product_id"
+			ids, err := insertedIDs(ctx, q, query, args)
+			if err != nil {
+				return nil, err
+			}
+			slices.Sort(ids)
+
+			for i, product := range chunk {
+				product.ID = formatID(ids[i])
+				product.Version = 1
+				product.Reserved = 0
+				events = append(events, domain.NewProductEvent(ctx, domain.ProductCreated, product.ID, product))
+			}
+		}
+		return events, nil
+	})
+}
+
+// insertedIDs runs an INSERT ... RETURNING product_id and collects the IDs.
+func insertedIDs(ctx context.Context, q queryer, query string, args []any) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UpdateProducts runs the conditional update of UpdateProduct for every
+// product in one transaction.
+func (r *ProductRepository) UpdateProducts(ctx context.Context, products []*domain.Product) error {
+	ids := make([]int64, len(products))
+	for i, product := range products {
+		id, err := parseID(product.ID)
+		if err != nil {
+			return &domain.BulkItemError{Index: i, Err: err}
+		}
+		ids[i] = id
+	}
+
+	return r.transaction(ctx, func(q queryer) ([]*domain.ProductEvent, error) {
+		events := make([]*domain.ProductEvent, 0, len(products))
+		for i, product := range products {
+			if err := updateProduct(ctx, q, ids[i], product); err != nil {
+				return nil, &domain.BulkItemError{Index: i, Err: err}
+			}
+			events = append(events, domain.NewProductEvent(ctx, domain.ProductUpdated, product.ID, product))
+		}
+		return events, nil
+	})
+}
+
+// DeleteProducts runs the conditional delete of DeleteProduct for every
+// ID in one transaction.
+func (r *ProductRepository) DeleteProducts(ctx context.Context, items []port.BulkDelete) error {
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		id, err := parseID(item.ID)
+		if err != nil {
+			return &domain.BulkItemError{Index: i, Err: err}
+		}
+		ids[i] = id
+	}
+
+	return r.transaction(ctx, func(q queryer) ([]*domain.ProductEvent, error) {
+		events := make([]*domain.ProductEvent, 0, len(items))
+		for i, item := range items {
+			if err := deleteProduct(ctx, q, ids[i], item.Version); err != nil {
+				return nil, &domain.BulkItemError{Index: i, Err: err}
+			}
+			events = append(events, domain.NewProductEvent(ctx, domain.ProductDeleted, item.ID, nil))
+		}
+		return events, nil
+	})
+}
@@ -0,0 +1,16 @@
+package postgres_repository
+
+import (
+	"goproduct/internals/adapter/repository/sqlquery"
+	"goproduct/internals/adapter/repository/sqlreservation"
+)
+
+// Reservations returns the stock reservations kept alongside the products.
+// Call it after EnableOutbox for stock movements to reach the outbox.
+func (r *ProductRepository) Reservations() *sqlreservation.Repository {
+	var opts []sqlreservation.Option
+	if r.outbox {
+		opts = append(opts, sqlreservation.WithOutbox())
+	}
+	return sqlreservation.New(r.db, sqlquery.Postgres, opts...)
+}
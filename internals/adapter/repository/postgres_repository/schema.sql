@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS Product (
+	product_id   BIGSERIAL        PRIMARY KEY,
+	product_name TEXT             NOT NULL,
+	price        DOUBLE PRECISION NOT NULL DEFAULT 0,
+	stock        INTEGER          NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS product_name_fts ON Product
+	USING GIN (to_tsvector('english', product_name));
+
+ALTER TABLE Product ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE Product ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS product_deleted_at ON Product (deleted_at);
+
+CREATE TABLE IF NOT EXISTS ProductAudit (
+	audit_id   BIGSERIAL   PRIMARY KEY,
+	product_id BIGINT      NOT NULL,
+	action     TEXT        NOT NULL,
+	actor      TEXT        NOT NULL,
+	changed_at TIMESTAMPTZ NOT NULL,
+	changes    JSONB       NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS product_audit_product ON ProductAudit (product_id, audit_id);
+
+CREATE TABLE IF NOT EXISTS ProductOutbox (
+	event_id     BIGSERIAL   PRIMARY KEY,
+	event_type   TEXT        NOT NULL,
+	product_id   TEXT        NOT NULL,
+	actor        TEXT        NOT NULL,
+	occurred_at  TIMESTAMPTZ NOT NULL,
+	payload      JSONB,
+	published_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS product_outbox_pending ON ProductOutbox (published_at, event_id);
+
+ALTER TABLE Product ADD COLUMN IF NOT EXISTS reserved INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS ProductReservation (
+	reservation_id TEXT        PRIMARY KEY,
+	product_id     BIGINT      NOT NULL,
+	quantity       INTEGER     NOT NULL,
+	status         TEXT        NOT NULL,
+	created_at     TIMESTAMPTZ NOT NULL,
+	expires_at     TIMESTAMPTZ NOT NULL,
+	settled_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS product_reservation_expiry ON ProductReservation (status, expires_at);
+
+ALTER TABLE ProductAudit ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,108 @@
+package shadow_repository
+
+import (
+	"encoding/csv"
+	"errors"
+	"goproduct/internals/core/product/domain"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// IDMap translates primary product IDs into shadow ones. Backends assign
+// their own IDs, so a product can only be mirrored once its pair is known.
+// Pairs are kept in memory and, when the map is backed by a file, appended
+// to it in the source_id,target_id format written by the transfer command.
+type IDMap struct {
+	mu    sync.RWMutex
+	ids   map[domain.ProductID]domain.ProductID
+	file  *os.File
+	lines *csv.Writer
+}
+
+// NewIDMap returns a map that lives in memory only.
+func NewIDMap() *IDMap {
+	return &IDMap{ids: map[domain.ProductID]domain.ProductID{}}
+}
+
+// OpenIDMap loads the pairs in the CSV file at path, creating it when
+// missing, and appends new pairs to it.
+func OpenIDMap(path string) (*IDMap, error) {
+	m := NewIDMap()
+
+	existing, err := os.Open(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	fresh := existing == nil
+	if existing != nil {
+		defer existing.Close()
+		records := csv.NewReader(existing)
+		records.FieldsPerRecord = 2
+		for first := true; ; first = false {
+			record, err := records.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if first && record[0] == "source_id" {
+				continue
+			}
+			m.ids[domain.ProductID(record[0])] = domain.ProductID(record[1])
+		}
+	}
+
+	if m.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err != nil {
+		return nil, err
+	}
+	m.lines = csv.NewWriter(m.file)
+	if fresh {
+		_ = m.lines.Write([]string{"source_id", "target_id"})
+		m.lines.Flush()
+	}
+	return m, nil
+}
+
+// Lookup returns the shadow ID of a primary product.
+func (m *IDMap) Lookup(primary domain.ProductID) (domain.ProductID, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	shadow, ok := m.ids[primary]
+	return shadow, ok
+}
+
+// Put records a pair, appending it to the backing file if there is one.
+func (m *IDMap) Put(primary, shadow domain.ProductID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ids[primary] = shadow
+	if m.lines == nil {
+		return nil
+	}
+	if err := m.lines.Write([]string{primary.String(), shadow.String()}); err != nil {
+		return err
+	}
+	m.lines.Flush()
+	return m.lines.Error()
+}
+
+// Len returns the number of known pairs.
+func (m *IDMap) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.ids)
+}
+
+// Close closes the backing file, if any.
+func (m *IDMap) Close() error {
+	if m.file == nil {
+		return nil
+	}
+	return m.file.Close()
+}
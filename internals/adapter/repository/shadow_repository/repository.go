@@ -0,0 +1,420 @@
+// Package shadow_repository mirrors the writes made to a primary
+// port.ProductRepository onto a shadow one, to bring a new backend up to
+// date and prove it before switching over. The primary stays the source of
+// truth: every read is served by it, and a failing shadow never fails a
+// request. Shadow calls get a deadline of their own, so a hung shadow
+// delays a write by no more than that. Products read by ID are compared
+// with their shadow copy in the background and every divergence is
+// reported.
+package shadow_repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCompareQueue is how many comparisons may wait for Run before
+// further ones are dropped.
+const DefaultCompareQueue = 1000
+
+// DefaultShadowTimeout bounds every call to the shadow.
+const DefaultShadowTimeout = 2 * time.Second
+
+// Divergence describes a shadow write that failed or a shadow copy that
+// does not match the primary.
+type Divergence struct {
+	Operation string
+	ProductID domain.ProductID
+	// Err is the error of the failed shadow call, or nil for a mismatch.
+	Err error
+	// Primary and Shadow are the copies that differ, for a mismatch.
+	Primary *domain.Product
+	Shadow  *domain.Product
+}
+
+func (d Divergence) String() string {
+	if d.Err != nil {
+		return fmt.Sprintf("%s %s: shadow failed: %v", d.Operation, d.ProductID, d.Err)
+	}
+	if d.Shadow == nil {
+		return fmt.Sprintf("%s %s: missing in shadow", d.Operation, d.ProductID)
+	}
+	return fmt.Sprintf("%s %s: primary {%q %v %d}, shadow {%q %v %d}", d.Operation, d.ProductID,
+		d.Primary.ProductName, d.Primary.Price, d.Primary.Stock,
+		d.Shadow.ProductName, d.Shadow.Price, d.Shadow.Stock)
+}
+
+// Stats counts shadow activity since the repository was created.
+type Stats struct {
+	Writes      uint64 `json:"writes"`
+	WriteErrors uint64 `json:"write_errors"`
+	// Unmapped counts writes skipped because the product has no shadow
+	// ID, typically because it predates the shadow.
+	Unmapped   uint64 `json:"unmapped"`
+	Compared   uint64 `json:"compared"`
+	Mismatches uint64 `json:"mismatches"`
+	Dropped    uint64 `json:"dropped"`
+}
+
+type comparison struct {
+	primary  *domain.Product
+	shadowID domain.ProductID
+}
+
+// ProductRepository writes to primary and shadow and reads from primary.
+// Methods it does not override only reach the primary.
+type ProductRepository struct {
+	port.ProductRepository
+
+	shadow       port.ProductRepository
+	ids          *IDMap
+	compare      chan comparison
+	onDivergence func(Divergence)
+	timeout      time.Duration
+
+	writes, writeErrors, unmapped atomic.Uint64
+	compared, mismatches, dropped atomic.Uint64
+}
+
+var _ port.ProductRepository = (*ProductRepository)(nil)
+
+// Option configures a ProductRepository.
+type Option func(*ProductRepository)
+
+// WithIDMap sets where shadow IDs are kept. By default they live in
+// memory, so only products created after startup are mirrored.
+func WithIDMap(ids *IDMap) Option {
+	return func(r *ProductRepository) {
+		r.ids = ids
+	}
+}
+
+// WithDivergenceHandler receives every divergence. They are only counted
+// by default.
+func WithDivergenceHandler(onDivergence func(Divergence)) Option {
+	return func(r *ProductRepository) {
+		r.onDivergence = onDivergence
+	}
+}
+
+// WithShadowTimeout bounds every call to the shadow, in place of
+// DefaultShadowTimeout.
+func WithShadowTimeout(timeout time.Duration) Option {
+	return func(r *ProductRepository) {
+		r.timeout = timeout
+	}
+}
+
+func NewProductRepository(primary, shadow port.ProductRepository, opts ...Option) *ProductRepository {
+	r := &ProductRepository{
+		ProductRepository: primary,
+		shadow:            shadow,
+		ids:               NewIDMap(),
+		compare:           make(chan comparison, DefaultCompareQueue),
+		onDivergence:      func(Divergence) {},
+		timeout:           DefaultShadowTimeout,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run compares the products queued by FindProductByID with their shadow
+// copies until ctx is done.
+func (r *ProductRepository) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-r.compare:
+			r.compareProduct(ctx, job)
+		}
+	}
+}
+
+// Stats returns the shadow counters.
+func (r *ProductRepository) Stats() Stats {
+	return Stats{
+		Writes:      r.writes.Load(),
+		WriteErrors: r.writeErrors.Load(),
+		Unmapped:    r.unmapped.Load(),
+		Compared:    r.compared.Load(),
+		Mismatches:  r.mismatches.Load(),
+		Dropped:     r.dropped.Load(),
+	}
+}
+
+func (r *ProductRepository) SaveProduct(ctx context.Context, product *domain.Product) error {
+	if err := r.ProductRepository.SaveProduct(ctx, product); err != nil {
+		return err
+	}
+
+	mirror := *product
+	mirror.ID = ""
+	shadowCtx, cancel := r.shadowContext(ctx)
+	defer cancel()
+	err := r.shadow.SaveProduct(shadowCtx, &mirror)
+	if err == nil {
+		err = r.ids.Put(product.ID, mirror.ID)
+	}
+	r.wrote("save", product.ID, err)
+	return nil
+}
+
+// FindProductByID answers from the primary and queues a comparison with
+// the shadow copy, dropping it when the queue is full.
+func (r *ProductRepository) FindProductByID(ctx context.Context, id domain.ProductID) (*domain.Product, error) {
+	product, err := r.ProductRepository.FindProductByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if shadowID, ok := r.ids.Lookup(id); ok {
+		primary := *product
+		select {
+		case r.compare <- comparison{primary: &primary, shadowID: shadowID}:
+		default:
+			r.dropped.Add(1)
+		}
+	}
+	return product, nil
+}
+
+// UpdateProduct mirrors the update unconditionally: versions are counted
+// per backend, and the primary has already settled the conflict.
+func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) error {
+	if err := r.ProductRepository.UpdateProduct(ctx, product); err != nil {
+		return err
+	}
+	r.mirror(ctx, "update", product.ID, func(ctx context.Context, shadowID domain.ProductID) error {
+		mirror := *product
+		mirror.ID = shadowID
+		mirror.Version = 0
+		return r.shadow.UpdateProduct(ctx, &mirror)
+	})
+	return nil
+}
+
+func (r *ProductRepository) AdjustStock(ctx context.Context, id domain.ProductID, delta int) (*domain.Product, error) {
+	product, err := r.ProductRepository.AdjustStock(ctx, id, delta)
+	if err != nil {
+		return nil, err
+	}
+	r.mirror(ctx, "adjust_stock", id, func(ctx context.Context, shadowID domain.ProductID) error {
+		_, err := r.shadow.AdjustStock(ctx, shadowID, delta)
+		return err
+	})
+	return product, nil
+}
+
+func (r *ProductRepository) DeleteProduct(ctx context.Context, id domain.ProductID, version int64) error {
+	if err := r.ProductRepository.DeleteProduct(ctx, id, version); err != nil {
+		return err
+	}
+	r.mirror(ctx, "delete", id, func(ctx context.Context, shadowID domain.ProductID) error {
+		return r.shadow.DeleteProduct(ctx, shadowID, 0)
+	})
+	return nil
+}
+
+func (r *ProductRepository) RestoreProduct(ctx context.Context, id domain.ProductID) error {
+	if err := r.ProductRepository.RestoreProduct(ctx, id); err != nil {
+		return err
+	}
+	r.mirror(ctx, "restore", id, func(ctx context.Context, shadowID domain.ProductID) error {
+		return r.shadow.RestoreProduct(ctx, shadowID)
+	})
+	return nil
+}
+
+func (r *ProductRepository) PurgeProduct(ctx context.Context, id domain.ProductID) error {
+	if err := r.ProductRepository.PurgeProduct(ctx, id); err != nil {
+		return err
+	}
+	r.mirror(ctx, "purge", id, func(ctx context.Context, shadowID domain.ProductID) error {
+		return r.shadow.PurgeProduct(ctx, shadowID)
+	})
+	return nil
+}
+
+// SaveProducts mirrors the products the primary saved, which under
+// domain.WithPartialWrites may be the ones before a failing product.
+func (r *ProductRepository) SaveProducts(ctx context.Context, products []*domain.Product) error {
+	primaryErr := r.ProductRepository.SaveProducts(ctx, products)
+	saved := products
+	var itemErr *domain.BulkItemError
+	switch {
+	case primaryErr == nil:
+	case domain.PartialWrites(ctx) && errors.As(primaryErr, &itemErr) && itemErr.Index >= 0 && itemErr.Index <= len(products):
+		saved = products[:itemErr.Index]
+	default:
+		return primaryErr
+	}
+	if len(saved) == 0 {
+		return primaryErr
+	}
+
+	mirrors := make([]*domain.Product, len(saved))
+	for i, product := range saved {
+		mirror := *product
+		mirror.ID = ""
+		mirrors[i] = &mirror
+	}
+	shadowCtx, cancel := r.shadowContext(domain.WithPartialWrites(ctx))
+	defer cancel()
+	err := r.shadow.SaveProducts(shadowCtx, mirrors)
+	var shadowErr *domain.BulkItemError
+	partial := errors.As(err, &shadowErr)
+	for i, product := range saved {
+		itemErr := err
+		if partial && i < shadowErr.Index {
+			itemErr = nil
+		}
+		if itemErr == nil {
+			itemErr = r.ids.Put(product.ID, mirrors[i].ID)
+		}
+		r.wrote("save", product.ID, itemErr)
+	}
+	return primaryErr
+}
+
+// UpdateProducts mirrors the products that have a shadow copy, as one
+// unconditional bulk update.
+func (r *ProductRepository) UpdateProducts(ctx context.Context, products []*domain.Product) error {
+	if err := r.ProductRepository.UpdateProducts(ctx, products); err != nil {
+		return err
+	}
+
+	var primaryIDs []domain.ProductID
+	var mirrors []*domain.Product
+	for _, product := range products {
+		if shadowID, ok := r.lookup(product.ID); ok {
+			mirror := *product
+			mirror.ID = shadowID
+			mirror.Version = 0
+			mirrors = append(mirrors, &mirror)
+			primaryIDs = append(primaryIDs, product.ID)
+		}
+	}
+	if len(mirrors) > 0 {
+		shadowCtx, cancel := r.shadowContext(ctx)
+		defer cancel()
+		r.wroteBulk("update", primaryIDs, r.shadow.UpdateProducts(shadowCtx, mirrors))
+	}
+	return nil
+}
+
+// DeleteProducts mirrors the deletes of products that have a shadow copy.
+func (r *ProductRepository) DeleteProducts(ctx context.Context, items []port.BulkDelete) error {
+	if err := r.ProductRepository.DeleteProducts(ctx, items); err != nil {
+		return err
+	}
+
+	var primaryIDs []domain.ProductID
+	var mirrors []port.BulkDelete
+	for _, item := range items {
+		if shadowID, ok := r.lookup(item.ID); ok {
+			mirrors = append(mirrors, port.BulkDelete{ID: shadowID})
+			primaryIDs = append(primaryIDs, item.ID)
+		}
+	}
+	if len(mirrors) > 0 {
+		shadowCtx, cancel := r.shadowContext(ctx)
+		defer cancel()
+		r.wroteBulk("delete", primaryIDs, r.shadow.DeleteProducts(shadowCtx, mirrors))
+	}
+	return nil
+}
+
+// mirror runs a shadow write for the product with primary ID id.
+func (r *ProductRepository) mirror(ctx context.Context, operation string, id domain.ProductID, write func(ctx context.Context, shadowID domain.ProductID) error) {
+	shadowID, ok := r.lookup(id)
+	if !ok {
+		return
+	}
+	shadowCtx, cancel := r.shadowContext(ctx)
+	defer cancel()
+	r.wrote(operation, id, write(shadowCtx, shadowID))
+}
+
+// shadowContext returns the context of a shadow call made on behalf of
+// ctx. It keeps the values of ctx but replaces its deadline with the
+// shadow timeout: the call is not cut short when the request that made
+// the primary write goes away, nor can it hold that request up for long.
+func (r *ProductRepository) shadowContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), r.timeout)
+}
+
+// lookup finds the shadow ID of a product, counting the misses.
+func (r *ProductRepository) lookup(id domain.ProductID) (domain.ProductID, bool) {
+	shadowID, ok := r.ids.Lookup(id)
+	if !ok {
+		r.unmapped.Add(1)
+	}
+	return shadowID, ok
+}
+
+func (r *ProductRepository) wrote(operation string, id domain.ProductID, err error) {
+	r.writes.Add(1)
+	if err != nil {
+		r.writeErrors.Add(1)
+		r.onDivergence(Divergence{Operation: operation, ProductID: id, Err: err})
+	}
+}
+
+// wroteBulk reports a failed bulk shadow write against the product it
+// names, or against all of them.
+func (r *ProductRepository) wroteBulk(operation string, ids []domain.ProductID, err error) {
+	var itemErr *domain.BulkItemError
+	for i, id := range ids {
+		switch {
+		case err == nil:
+			r.wrote(operation, id, nil)
+		case errors.As(err, &itemErr) && itemErr.Index != i:
+			r.wrote(operation, id, domain.ErrBulkAborted)
+		default:
+			r.wrote(operation, id, err)
+		}
+	}
+}
+
+func (r *ProductRepository) compareProduct(ctx context.Context, job comparison) {
+	r.compared.Add(1)
+	shadowCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	shadow, err := r.shadow.FindProductByID(shadowCtx, job.shadowID)
+	if errors.Is(err, domain.ErrNotFound) {
+		shadow, err = nil, nil
+	}
+	if err != nil {
+		r.onDivergence(Divergence{Operation: "compare", ProductID: job.primary.ID, Err: err})
+		return
+	}
+	if same(job.primary, shadow) {
+		return
+	}
+
+	// The product may have changed since it was queued; only a difference
+	// from the current primary counts.
+	primary, err := r.ProductRepository.FindProductByID(ctx, job.primary.ID)
+	if errors.Is(err, domain.ErrNotFound) && shadow == nil {
+		return
+	}
+	if err != nil || same(primary, shadow) {
+		return
+	}
+	r.mismatches.Add(1)
+	r.onDivergence(Divergence{Operation: "compare", ProductID: primary.ID, Primary: primary, Shadow: shadow})
+}
+
+// same compares the fields both backends store alike.
+func same(primary, shadow *domain.Product) bool {
+	return shadow != nil && shadow.ProductName == primary.ProductName &&
+		shadow.Price == primary.Price && shadow.Stock == primary.Stock
+}
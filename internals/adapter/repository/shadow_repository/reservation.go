@@ -0,0 +1,48 @@
+package shadow_repository
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"time"
+)
+
+// reservations mirrors the stock that reservations of the primary hold and
+// give back as plain stock adjustments of the shadow, which keeps its
+// stock in step without copying the reservations themselves.
+type reservations struct {
+	port.ReservationRepository
+	shadow *ProductRepository
+}
+
+// Reservations wraps the reservation repository of the primary backend.
+func (r *ProductRepository) Reservations(inner port.ReservationRepository) port.ReservationRepository {
+	return &reservations{ReservationRepository: inner, shadow: r}
+}
+
+func (r *reservations) CreateReservation(ctx context.Context, reservation *domain.Reservation) error {
+	if err := r.ReservationRepository.CreateReservation(ctx, reservation); err != nil {
+		return err
+	}
+	r.adjust(ctx, "reserve", reservation.ProductID, -reservation.Quantity)
+	return nil
+}
+
+func (r *reservations) SettleReservation(ctx context.Context, id string, status domain.ReservationStatus, at time.Time) (*domain.Reservation, error) {
+	reservation, err := r.ReservationRepository.SettleReservation(ctx, id, status, at)
+	if err != nil {
+		return nil, err
+	}
+	// Committed stock is gone for good, which the shadow already reflects
+	if status != domain.ReservationCommitted {
+		r.adjust(ctx, "release", reservation.ProductID, reservation.Quantity)
+	}
+	return reservation, nil
+}
+
+func (r *reservations) adjust(ctx context.Context, operation string, id domain.ProductID, delta int) {
+	r.shadow.mirror(ctx, operation, id, func(ctx context.Context, shadowID domain.ProductID) error {
+		_, err := r.shadow.shadow.AdjustStock(ctx, shadowID, delta)
+		return err
+	})
+}
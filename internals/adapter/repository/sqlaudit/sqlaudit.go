@@ -0,0 +1,108 @@
+// Package sqlaudit stores the product change history in the ProductAudit
+// table for the SQL repository adapters, next to the products themselves.
+package sqlaudit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"goproduct/internals/adapter/repository/sqlquery"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"strconv"
+	"strings"
+)
+
+type Repository struct {
+	db      *sql.DB
+	dialect sqlquery.Dialect
+}
+
+var _ port.AuditRepository = (*Repository)(nil)
+
+func New(db *sql.DB, dialect sqlquery.Dialect) *Repository {
+	return &Repository{db: db, dialect: dialect}
+}
+
+func (r *Repository) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	productID, err := parseID(entry.ProductID)
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := r.bind("INSERT INTO ProductAudit (product_id, action, actor, changed_at, reason, changes) VALUES (?, ?, ?, ?, ?, ?)")
+	args := []any{productID, entry.Action, entry.Actor, entry.At, entry.Reason, string(changes)}
+	var id int64
+	if r.dialect.Returning {
+		err = r.db.QueryRowContext(ctx, query+" RETURNING audit_id", args...).Scan(&id)
+	} else {
+		var result sql.Result
+		if result, err = r.db.ExecContext(ctx, query, args...); err == nil {
+			id, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	entry.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+func (r *Repository) ListAudit(ctx context.Context, productID domain.ProductID) ([]*domain.AuditEntry, error) {
+	id, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.bind(`SELECT audit_id, action, actor, changed_at, reason, changes
+		FROM ProductAudit WHERE product_id = ? ORDER BY audit_id`)
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*domain.AuditEntry{}
+	for rows.Next() {
+		var auditID int64
+		var changes []byte
+		entry := domain.AuditEntry{ProductID: productID}
+		if err := rows.Scan(&auditID, &entry.Action, &entry.Actor, &entry.At, &entry.Reason, &changes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, err
+		}
+		entry.ID = strconv.FormatInt(auditID, 10)
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// bind replaces each "?" in query with the dialect's placeholder.
+func (r *Repository) bind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, part := range strings.SplitAfter(query, "?") {
+		if strings.HasSuffix(part, "?") {
+			n++
+			part = strings.TrimSuffix(part, "?") + r.dialect.Placeholder(n)
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// parseID maps a domain ID onto the auto-increment product_id column.
+func parseID(id domain.ProductID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, domain.ErrInvalidProductID
+	}
+	return n, nil
+}
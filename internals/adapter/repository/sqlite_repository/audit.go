@@ -0,0 +1,12 @@
+package sqlite_repository
+
+import (
+	"goproduct/internals/adapter/repository/sqlaudit"
+	"goproduct/internals/adapter/repository/sqlquery"
+)
+
+// AuditRepository returns the product change history kept in the
+// ProductAudit table, sharing the product repository's connection pool.
+func (r *ProductRepository) AuditRepository() *sqlaudit.Repository {
+	return sqlaudit.New(r.db, sqlquery.SQLite)
+}
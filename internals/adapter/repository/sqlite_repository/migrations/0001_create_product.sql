@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS Product (
+	product_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_name TEXT    NOT NULL,
+	price        REAL    NOT NULL DEFAULT 0,
+	stock        INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,21 @@
+-- Full-text index over product names, kept in sync by triggers
+CREATE VIRTUAL TABLE IF NOT EXISTS product_fts USING fts4(content="Product", product_name);
+
+CREATE TRIGGER IF NOT EXISTS product_fts_insert AFTER INSERT ON Product BEGIN
+	INSERT INTO product_fts(docid, product_name) VALUES (new.product_id, new.product_name);
+END;
+
+CREATE TRIGGER IF NOT EXISTS product_fts_update_before BEFORE UPDATE ON Product BEGIN
+	DELETE FROM product_fts WHERE docid = old.product_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS product_fts_update_after AFTER UPDATE ON Product BEGIN
+	INSERT INTO product_fts(docid, product_name) VALUES (new.product_id, new.product_name);
+END;
+
+CREATE TRIGGER IF NOT EXISTS product_fts_delete BEFORE DELETE ON Product BEGIN
+	DELETE FROM product_fts WHERE docid = old.product_id;
+END;
+
+-- Index rows written before the full-text table existed
+INSERT INTO product_fts(product_fts) VALUES ('rebuild');
@@ -0,0 +1 @@
+ALTER TABLE Product ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+ALTER TABLE Product ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX idx_product_deleted_at ON Product (deleted_at);
@@ -0,0 +1,10 @@
+CREATE TABLE ProductAudit (
+	audit_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER  NOT NULL,
+	action     TEXT     NOT NULL,
+	actor      TEXT     NOT NULL,
+	changed_at DATETIME NOT NULL,
+	changes    TEXT     NOT NULL
+);
+
+CREATE INDEX idx_product_audit_product ON ProductAudit (product_id, audit_id);
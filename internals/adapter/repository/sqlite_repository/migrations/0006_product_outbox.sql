@@ -0,0 +1,11 @@
+CREATE TABLE ProductOutbox (
+	event_id     INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_type   TEXT     NOT NULL,
+	product_id   TEXT     NOT NULL,
+	actor        TEXT     NOT NULL,
+	occurred_at  DATETIME NOT NULL,
+	payload      TEXT,
+	published_at DATETIME
+);
+
+CREATE INDEX idx_product_outbox_pending ON ProductOutbox (published_at, event_id);
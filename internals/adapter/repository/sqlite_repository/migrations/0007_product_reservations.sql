@@ -0,0 +1,13 @@
+ALTER TABLE Product ADD COLUMN reserved INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE ProductReservation (
+	reservation_id TEXT     PRIMARY KEY,
+	product_id     INTEGER  NOT NULL,
+	quantity       INTEGER  NOT NULL,
+	status         TEXT     NOT NULL,
+	created_at     DATETIME NOT NULL,
+	expires_at     DATETIME NOT NULL,
+	settled_at     DATETIME
+);
+
+CREATE INDEX idx_product_reservation_expiry ON ProductReservation (status, expires_at);
@@ -0,0 +1 @@
+ALTER TABLE ProductAudit ADD COLUMN reason TEXT NOT NULL DEFAULT '';
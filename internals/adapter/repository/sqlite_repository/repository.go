@@ -0,0 +1,437 @@
+package sqlite_repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"goproduct/internals/adapter/repository/sqlquery"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// productColumns is the column list scanProduct expects.
+const productColumns = "product_id, product_name, price, stock, reserved, version, deleted_at"
+
+type ProductRepository struct {
+	db     *sql.DB
+	outbox bool
+}
+
+var _ port.ProductRepository = (*ProductRepository)(nil)
+
+func NewProductRepository(path string) (*ProductRepository, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite serializes writers anyway; a single connection avoids
+	// "database is locked" errors and keeps ":memory:" databases shared.
+	db.SetMaxOpenConns(1)
+
+	// Check the connection
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create or upgrade the schema
+	err = migrate(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProductRepository{db: db}, nil
+}
+
+func (r *ProductRepository) SaveProduct(ctx context.Context, product *domain.Product) error {
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		query := "INSERT INTO Product (product_name, price, stock) VALUES (?, ?, ?)"
+		result, err := q.ExecContext(ctx, query, product.ProductName, product.Price, product.Stock)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		product.ID = formatID(id)
+		product.Version = 1
+		product.Reserved = 0
+		return domain.NewProductEvent(ctx, domain.ProductCreated, product.ID, product), nil
+	})
+}
+
+func (r *ProductRepository) FindProductByID(ctx context.Context, productID domain.ProductID) (*domain.Product, error) {
+	id, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + productColumns + " FROM Product WHERE product_id = ? AND deleted_at IS NULL"
+	product, err := scanProduct(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r *ProductRepository) GetAllProducts(ctx context.Context) ([]*domain.Product, error) {
+	var products []*domain.Product
+	err := r.StreamProducts(ctx, func(product *domain.Product) error {
+		products = append(products, product)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// streamPageSize is the number of rows StreamProducts reads at a time.
+const streamPageSize = 500
+
+// StreamProducts reads the products in keyset pages and hands them to fn
+// between reads. The repository has a single connection, so holding a
+// cursor open while fn waits on a slow consumer would stall every other
+// query.
+func (r *ProductRepository) StreamProducts(ctx context.Context, fn func(*domain.Product) error) error {
+	query := port.ProductQuery{
+		Sort:  []port.SortKey{{Field: port.SortByID}},
+		Limit: streamPageSize,
+	}
+	for {
+		page, err := r.ListProducts(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, product := range page.Products {
+			if err := fn(product); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// ListProducts runs a keyset query built from the filter and sort,
+// fetching one extra row to find out whether another page follows.
+func (r *ProductRepository) ListProducts(ctx context.Context, query port.ProductQuery) (*port.Page, error) {
+	cursor, err := query.Decode()
+	if err != nil {
+		return nil, err
+	}
+	var afterID int64
+	if !cursor.IsZero() {
+		if afterID, err = parseID(cursor.AfterID); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := query.OrderKeys()
+	builder := sqlquery.NewBuilder(sqlquery.SQLite)
+	builder.Filter(query.Filter)
+	builder.After(keys, cursor, afterID)
+	statement, args := builder.Select(productColumns, "Product", keys, query.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]*domain.Product, 0, query.Limit)
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return query.NewPage(products), nil
+}
+
+// SearchProducts matches any of the query terms against the product_fts
+// index. FTS4 has no built-in ranking, so results are ordered by the number
+// of term hits reported by offsets(), preferring shorter names on ties.
+func (r *ProductRepository) SearchProducts(ctx context.Context, query string, limit int) ([]*domain.Product, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []*domain.Product{}, nil
+	}
+
+	// offsets() yields four integers per hit, separated by spaces
+	statement := `SELECT p.product_id, p.product_name, p.price, p.stock, p.reserved, p.version, p.deleted_at
+		FROM product_fts JOIN Product p ON p.product_id = product_fts.docid
+		WHERE product_fts MATCH ? AND p.deleted_at IS NULL
+		ORDER BY (length(offsets(product_fts)) - length(replace(offsets(product_fts), ' ', '')) + 1) / 4 DESC,
+			length(p.product_name), p.product_id
+		LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, statement, strings.Join(terms, " OR "), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]*domain.Product, 0, limit)
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, rows.Err()
+}
+
+// UpdateProduct bumps the version in the same statement as the
+// compare-and-swap and reads it back with RETURNING.
+func (r *ProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) error {
+	id, err := parseID(product.ID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		if err := updateProduct(ctx, q, id, product); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductUpdated, product.ID, product), nil
+	})
+}
+
+// updateProduct replaces the product with the given ID if product.Version
+// is still current, or unconditionally when it is zero, and stores the new
+// version in product.Version.
+func updateProduct(ctx context.Context, q queryer, id int64, product *domain.Product) error {
+	query := `UPDATE Product SET product_name = ?, price = ?, stock = ?, version = version + 1
+		WHERE product_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+		RETURNING version`
+	err := q.QueryRowContext(ctx, query,
+		product.ProductName, product.Price, product.Stock, id, product.Version, product.Version).Scan(&product.Version)
+	if err == sql.ErrNoRows {
+		return missingOrStale(ctx, q, id)
+	}
+	if err != nil {
+		return translateError(err)
+	}
+	return nil
+}
+
+// AdjustStock applies delta in a single conditional update that refuses to
+// take the stock below zero, reading the product back with RETURNING.
+func (r *ProductRepository) AdjustStock(ctx context.Context, productID domain.ProductID, delta int) (*domain.Product, error) {
+	id, err := parseID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	var product *domain.Product
+	err = r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		query := `UPDATE Product SET stock = stock + ?, version = version + 1
+			WHERE product_id = ? AND deleted_at IS NULL AND stock + ? >= 0
+			RETURNING ` + productColumns
+		product, err = scanProduct(q.QueryRowContext(ctx, query, delta, id, delta))
+		if err == sql.ErrNoRows {
+			return nil, missingOrShort(ctx, q, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductUpdated, productID, product), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r *ProductRepository) DeleteProduct(ctx context.Context, productID domain.ProductID, version int64) error {
+	id, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		if err := deleteProduct(ctx, q, id, version); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductDeleted, productID, nil), nil
+	})
+}
+
+// deleteProduct moves the product with the given ID to the trash if
+// version is still current, or unconditionally when it is zero.
+func deleteProduct(ctx context.Context, q queryer, id int64, version int64) error {
+	query := `UPDATE Product SET deleted_at = ?, version = version + 1
+		WHERE product_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`
+	result, err := q.ExecContext(ctx, query, time.Now().UTC(), id, version, version)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return missingOrStale(ctx, q, id)
+	}
+	return nil
+}
+
+// RestoreProduct clears the deletion marker, bumping the version like any
+// other write.
+func (r *ProductRepository) RestoreProduct(ctx context.Context, productID domain.ProductID) error {
+	id, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		query := "UPDATE Product SET deleted_at = NULL, version = version + 1 WHERE product_id = ? AND deleted_at IS NOT NULL"
+		result, err := q.ExecContext(ctx, query, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := expectAffected(result); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductRestored, productID, nil), nil
+	})
+}
+
+func (r *ProductRepository) PurgeProduct(ctx context.Context, productID domain.ProductID) error {
+	id, err := parseID(productID)
+	if err != nil {
+		return err
+	}
+
+	return r.write(ctx, func(q queryer) (*domain.ProductEvent, error) {
+		query := "DELETE FROM Product WHERE product_id = ?"
+		result, err := q.ExecContext(ctx, query, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := expectAffected(result); err != nil {
+			return nil, err
+		}
+		return domain.NewProductEvent(ctx, domain.ProductPurged, productID, nil), nil
+	})
+}
+
+// translateError maps driver errors onto the domain taxonomy.
+func translateError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return fmt.Errorf("%w: %s", domain.ErrConflict, sqliteErr.Error())
+	}
+	return err
+}
+
+// searchTerms splits a search query into quoted FTS terms, dropping
+// anything that the MATCH syntax would interpret as an operator.
+func searchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"`
+	}
+	return terms
+}
+
+// expectAffected turns a statement that matched no rows into ErrNotFound.
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// missingOrStale explains a conditional write that matched no rows: the
+// product is either gone or carries another version.
+func missingOrStale(ctx context.Context, q queryer, id int64) error {
+	exists, err := productExists(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrNotFound
+	}
+	return domain.ErrVersionMismatch
+}
+
+// missingOrShort explains a stock adjustment that matched no rows: the
+// product is either gone or has too little stock.
+func missingOrShort(ctx context.Context, q queryer, id int64) error {
+	exists, err := productExists(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrNotFound
+	}
+	return domain.ErrInsufficientStock
+}
+
+// productExists reports whether a live product has the given ID.
+func productExists(ctx context.Context, q queryer, id int64) (bool, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM Product WHERE product_id = ? AND deleted_at IS NULL)", id).Scan(&exists)
+	return exists, err
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProduct(row scanner) (*domain.Product, error) {
+	var id int64
+	var deletedAt sql.NullTime
+	var product domain.Product
+	if err := row.Scan(&id, &product.ProductName, &product.Price, &product.Stock, &product.Reserved, &product.Version, &deletedAt); err != nil {
+		return nil, err
+	}
+	product.ID = formatID(id)
+	if deletedAt.Valid {
+		product.DeletedAt = &deletedAt.Time
+	}
+	return &product, nil
+}
+
+// parseID maps a domain ID onto the auto-increment product_id column.
+func parseID(id domain.ProductID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, domain.ErrInvalidProductID
+	}
+	return n, nil
+}
+
+func formatID(id int64) domain.ProductID {
+	return domain.ProductID(strconv.FormatInt(id, 10))
+}
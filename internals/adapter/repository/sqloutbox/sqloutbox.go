@@ -0,0 +1,117 @@
+// Package sqloutbox stores product events in the ProductOutbox table for
+// the SQL repository adapters. Events are written with Record inside the
+// transaction of the product change and read back through Outbox.
+package sqloutbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"goproduct/internals/adapter/repository/sqlquery"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Execer is implemented by both *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Record appends event to the outbox through tx. The event ID is the
+// table's auto-increment key and is only known to readers.
+func Record(ctx context.Context, tx Execer, dialect sqlquery.Dialect, event *domain.ProductEvent) error {
+	var payload []byte
+	if event.Product != nil {
+		var err error
+		if payload, err = json.Marshal(event.Product); err != nil {
+			return err
+		}
+	}
+
+	query := "INSERT INTO ProductOutbox (event_type, product_id, actor, occurred_at, payload) VALUES (" +
+		placeholders(dialect, 1, 5) + ")"
+	_, err := tx.ExecContext(ctx, query,
+		string(event.Type), event.ProductID.String(), event.Actor, event.OccurredAt, nullString(payload))
+	return err
+}
+
+// Outbox reads pending events from the ProductOutbox table.
+type Outbox struct {
+	db      *sql.DB
+	dialect sqlquery.Dialect
+}
+
+var _ port.OutboxRepository = (*Outbox)(nil)
+
+func New(db *sql.DB, dialect sqlquery.Dialect) *Outbox {
+	return &Outbox{db: db, dialect: dialect}
+}
+
+func (o *Outbox) PendingEvents(ctx context.Context, limit int) ([]*domain.ProductEvent, error) {
+	query := `SELECT event_id, event_type, product_id, actor, occurred_at, payload
+		FROM ProductOutbox WHERE published_at IS NULL
+		ORDER BY event_id LIMIT ` + strconv.Itoa(limit)
+	rows, err := o.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*domain.ProductEvent{}
+	for rows.Next() {
+		var id int64
+		var eventType, productID string
+		var payload sql.NullString
+		event := &domain.ProductEvent{}
+		if err := rows.Scan(&id, &eventType, &productID, &event.Actor, &event.OccurredAt, &payload); err != nil {
+			return nil, err
+		}
+		if payload.Valid {
+			if err := json.Unmarshal([]byte(payload.String), &event.Product); err != nil {
+				return nil, err
+			}
+		}
+		event.ID = strconv.FormatInt(id, 10)
+		event.Type = domain.EventType(eventType)
+		event.ProductID = domain.ProductID(productID)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (o *Outbox) MarkPublished(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []any{time.Now().UTC()}
+	for _, id := range ids {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return domain.NewValidationError("id", "invalid event ID")
+		}
+		args = append(args, n)
+	}
+
+	query := "UPDATE ProductOutbox SET published_at = " + o.dialect.Placeholder(1) +
+		" WHERE event_id IN (" + placeholders(o.dialect, 2, len(ids)) + ")"
+	_, err := o.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// placeholders renders count bind parameters starting at the first-th.
+func placeholders(dialect sqlquery.Dialect, first, count int) string {
+	list := make([]string, count)
+	for i := range list {
+		list[i] = dialect.Placeholder(first + i)
+	}
+	return strings.Join(list, ", ")
+}
+
+func nullString(b []byte) sql.NullString {
+	return sql.NullString{String: string(b), Valid: b != nil}
+}
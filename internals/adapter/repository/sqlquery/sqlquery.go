@@ -0,0 +1,163 @@
+// Package sqlquery translates port.ProductQuery into parameterized SQL for
+// the SQL repository adapters, which share the Product table layout.
+package sqlquery
+
+import (
+	"goproduct/internals/core/product/port"
+	"strconv"
+	"strings"
+)
+
+// Dialect captures the SQL differences between the backends.
+type Dialect struct {
+	// Placeholder returns the bind parameter for the n-th argument,
+	// counting from 1.
+	Placeholder func(n int) string
+	// ILike is the case-insensitive pattern match operator.
+	ILike string
+	// Returning is set when generated keys come back through
+	// INSERT ... RETURNING rather than sql.Result.LastInsertId.
+	Returning bool
+}
+
+var (
+	MySQL = Dialect{
+		Placeholder: func(int) string { return "?" },
+		ILike:       "LIKE",
+	}
+	SQLite = Dialect{
+		Placeholder: func(int) string { return "?" },
+		ILike:       "LIKE",
+	}
+	Postgres = Dialect{
+		Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		ILike:       "ILIKE",
+		Returning:   true,
+	}
+)
+
+// Columns maps sort fields onto Product table columns.
+var Columns = map[port.SortField]string{
+	port.SortByID:    "product_id",
+	port.SortByName:  "product_name",
+	port.SortByPrice: "price",
+	port.SortByStock: "stock",
+}
+
+// Builder accumulates the WHERE conditions of a query and their arguments.
+type Builder struct {
+	dialect    Dialect
+	conditions []string
+	args       []any
+}
+
+func NewBuilder(dialect Dialect) *Builder {
+	return &Builder{dialect: dialect}
+}
+
+// Where adds a condition. Each "?" in condition is bound to the next value
+// of args, using the dialect's placeholder syntax.
+func (b *Builder) Where(condition string, args ...any) {
+	b.conditions = append(b.conditions, b.bind(condition, args))
+}
+
+// Filter adds the conditions expressed by a ProductFilter, including the
+// choice between live products and the trash.
+func (b *Builder) Filter(filter port.ProductFilter) {
+	if filter.Deleted {
+		b.Where("deleted_at IS NOT NULL")
+	} else {
+		b.Where("deleted_at IS NULL")
+	}
+	if filter.PriceMin != nil {
+		b.Where("price >= ?", *filter.PriceMin)
+	}
+	if filter.PriceMax != nil {
+		b.Where("price <= ?", *filter.PriceMax)
+	}
+	if filter.StockLT != nil {
+		b.Where("stock < ?", *filter.StockLT)
+	}
+	if filter.NameContains != "" {
+		b.Where("product_name "+b.dialect.ILike+" ? ESCAPE '!'", "%"+escapeLike(filter.NameContains)+"%")
+	}
+}
+
+// After adds the keyset condition that resumes right after cursor in the
+// given order. afterID is the cursor ID in the backend's native form.
+func (b *Builder) After(keys []port.SortKey, cursor port.Cursor, afterID any) {
+	if cursor.IsZero() {
+		return
+	}
+
+	value := func(field port.SortField) any {
+		if field == port.SortByID {
+			return afterID
+		}
+		return cursor.Value(field)
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+	var alternatives []string
+	for i, key := range keys {
+		var terms []string
+		var args []any
+		for _, previous := range keys[:i] {
+			terms = append(terms, Columns[previous.Field]+" = ?")
+			args = append(args, value(previous.Field))
+		}
+		operator := " > ?"
+		if key.Descending {
+			operator = " < ?"
+		}
+		terms = append(terms, Columns[key.Field]+operator)
+		args = append(args, value(key.Field))
+
+		alternatives = append(alternatives, "("+b.bind(strings.Join(terms, " AND "), args)+")")
+	}
+	b.conditions = append(b.conditions, "("+strings.Join(alternatives, " OR ")+")")
+}
+
+// Select renders "SELECT columns FROM table WHERE ... ORDER BY ... LIMIT n"
+// and returns it with its arguments. A limit below one is left out.
+func (b *Builder) Select(columns, table string, keys []port.SortKey, limit int) (string, []any) {
+	var query strings.Builder
+	query.WriteString("SELECT " + columns + " FROM " + table)
+	if len(b.conditions) > 0 {
+		query.WriteString(" WHERE " + strings.Join(b.conditions, " AND "))
+	}
+	if len(keys) > 0 {
+		order := make([]string, len(keys))
+		for i, key := range keys {
+			order[i] = Columns[key.Field]
+			if key.Descending {
+				order[i] += " DESC"
+			}
+		}
+		query.WriteString(" ORDER BY " + strings.Join(order, ", "))
+	}
+	if limit > 0 {
+		query.WriteString(" LIMIT " + strconv.Itoa(limit))
+	}
+	return query.String(), b.args
+}
+
+func (b *Builder) bind(condition string, args []any) string {
+	var bound strings.Builder
+	for _, r := range condition {
+		if r == '?' && len(args) > 0 {
+			b.args = append(b.args, args[0])
+			args = args[1:]
+			bound.WriteString(b.dialect.Placeholder(len(b.args)))
+			continue
+		}
+		bound.WriteRune(r)
+	}
+	return bound.String()
+}
+
+// escapeLike escapes the LIKE wildcards in s using '!' as escape
+// character, which needs no quoting in any of the dialects.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
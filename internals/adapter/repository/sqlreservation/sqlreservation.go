@@ -0,0 +1,273 @@
+// Package sqlreservation stores stock reservations in the
+// ProductReservation table for the SQL repository adapters. Each change to
+// a reservation and the matching stock movement on the Product row run in
+// one transaction, and every step is a conditional write, so concurrent
+// reservations can neither oversell nor settle a hold twice. With the
+// outbox enabled, the same transaction records the product's new state as
+// a ProductUpdated event.
+package sqlreservation
+
+import (
+	"context"
+	"database/sql"
+	"goproduct/internals/adapter/repository/sqloutbox"
+	"goproduct/internals/adapter/repository/sqlquery"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reservationColumns is the column list scanReservation expects.
+const reservationColumns = "reservation_id, product_id, quantity, status, created_at, expires_at, settled_at"
+
+// productColumns is the column list scanProduct expects.
+const productColumns = "product_id, product_name, price, stock, reserved, version, deleted_at"
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Repository struct {
+	db      *sql.DB
+	dialect sqlquery.Dialect
+	outbox  bool
+}
+
+var _ port.ReservationRepository = (*Repository)(nil)
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithOutbox records an event in the ProductOutbox table for every stock
+// movement, like the product repository it belongs to.
+func WithOutbox() Option {
+	return func(r *Repository) {
+		r.outbox = true
+	}
+}
+
+func New(db *sql.DB, dialect sqlquery.Dialect, opts ...Option) *Repository {
+	r := &Repository{db: db, dialect: dialect}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Repository) CreateReservation(ctx context.Context, reservation *domain.Reservation) error {
+	productID, err := parseID(reservation.ProductID)
+	if err != nil {
+		return err
+	}
+
+	return r.transaction(ctx, func(tx *sql.Tx) error {
+		query := r.bind(`UPDATE Product SET stock = stock - ?, reserved = reserved + ?, version = version + 1
+			WHERE product_id = ? AND deleted_at IS NULL AND stock >= ?`)
+		result, err := tx.ExecContext(ctx, query, reservation.Quantity, reservation.Quantity, productID, reservation.Quantity)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return r.missingOrShort(ctx, tx, productID)
+		}
+
+		query = r.bind("INSERT INTO ProductReservation (" + reservationColumns + ") VALUES (?, ?, ?, ?, ?, ?, NULL)")
+		_, err = tx.ExecContext(ctx, query, reservation.ID, productID, reservation.Quantity, string(reservation.Status),
+			reservation.CreatedAt.UTC(), reservation.ExpiresAt.UTC())
+		if err != nil {
+			return err
+		}
+		return r.recordUpdate(ctx, tx, productID)
+	})
+}
+
+func (r *Repository) FindReservation(ctx context.Context, id string) (*domain.Reservation, error) {
+	return r.find(ctx, r.db, id)
+}
+
+// SettleReservation claims the reservation with a conditional update
+// before touching the product, so only one settlement of a hold wins.
+func (r *Repository) SettleReservation(ctx context.Context, id string, status domain.ReservationStatus, at time.Time) (*domain.Reservation, error) {
+	var reservation *domain.Reservation
+	err := r.transaction(ctx, func(tx *sql.Tx) error {
+		query := r.bind("UPDATE ProductReservation SET status = ?, settled_at = ? WHERE reservation_id = ? AND status = ?")
+		result, err := tx.ExecContext(ctx, query, string(status), at.UTC(), id, string(domain.ReservationHeld))
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			if _, err := r.find(ctx, tx, id); err != nil {
+				return err
+			}
+			return domain.ErrReservationNotHeld
+		}
+
+		if reservation, err = r.find(ctx, tx, id); err != nil {
+			return err
+		}
+		productID, err := parseID(reservation.ProductID)
+		if err != nil {
+			return err
+		}
+
+		// A purged product has nothing left to give the stock back to.
+		query = "UPDATE Product SET stock = stock + ?, reserved = reserved - ?, version = version + 1 WHERE product_id = ?"
+		restock := reservation.Quantity
+		if status == domain.ReservationCommitted {
+			restock = 0
+		}
+		result, err = tx.ExecContext(ctx, r.bind(query), restock, reservation.Quantity, productID)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+		return r.recordUpdate(ctx, tx, productID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (r *Repository) ExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*domain.Reservation, error) {
+	query := r.bind("SELECT " + reservationColumns + ` FROM ProductReservation
+		WHERE status = ? AND expires_at < ? ORDER BY expires_at LIMIT ` + strconv.Itoa(limit))
+	rows, err := r.db.QueryContext(ctx, query, string(domain.ReservationHeld), now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := []*domain.Reservation{}
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, rows.Err()
+}
+
+func (r *Repository) find(ctx context.Context, q queryer, id string) (*domain.Reservation, error) {
+	query := r.bind("SELECT " + reservationColumns + " FROM ProductReservation WHERE reservation_id = ?")
+	reservation, err := scanReservation(q.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrReservationNotFound
+	}
+	return reservation, err
+}
+
+// recordUpdate records the product as it now is in the outbox, when it is
+// enabled.
+func (r *Repository) recordUpdate(ctx context.Context, tx *sql.Tx, productID int64) error {
+	if !r.outbox {
+		return nil
+	}
+
+	query := r.bind("SELECT " + productColumns + " FROM Product WHERE product_id = ?")
+	product, err := scanProduct(tx.QueryRowContext(ctx, query, productID))
+	if err != nil {
+		return err
+	}
+	return sqloutbox.Record(ctx, tx, r.dialect, domain.NewProductEvent(ctx, domain.ProductUpdated, product.ID, product))
+}
+
+// missingOrShort explains a reservation that matched no product row: the
+// product is either gone or has too little stock.
+func (r *Repository) missingOrShort(ctx context.Context, q queryer, productID int64) error {
+	var exists bool
+	query := r.bind("SELECT EXISTS (SELECT 1 FROM Product WHERE product_id = ? AND deleted_at IS NULL)")
+	if err := q.QueryRowContext(ctx, query, productID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrNotFound
+	}
+	return domain.ErrInsufficientStock
+}
+
+// transaction runs fn in a transaction, committing unless it fails.
+func (r *Repository) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// bind replaces each "?" in query with the dialect's placeholder.
+func (r *Repository) bind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, part := range strings.SplitAfter(query, "?") {
+		if strings.HasSuffix(part, "?") {
+			n++
+			part = strings.TrimSuffix(part, "?") + r.dialect.Placeholder(n)
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReservation(row scanner) (*domain.Reservation, error) {
+	var productID int64
+	var status string
+	var settledAt sql.NullTime
+	var reservation domain.Reservation
+	if err := row.Scan(&reservation.ID, &productID, &reservation.Quantity, &status,
+		&reservation.CreatedAt, &reservation.ExpiresAt, &settledAt); err != nil {
+		return nil, err
+	}
+	reservation.ProductID = domain.ProductID(strconv.FormatInt(productID, 10))
+	reservation.Status = domain.ReservationStatus(status)
+	if settledAt.Valid {
+		reservation.SettledAt = &settledAt.Time
+	}
+	return &reservation, nil
+}
+
+func scanProduct(row scanner) (*domain.Product, error) {
+	var id int64
+	var deletedAt sql.NullTime
+	var product domain.Product
+	if err := row.Scan(&id, &product.ProductName, &product.Price, &product.Stock,
+		&product.Reserved, &product.Version, &deletedAt); err != nil {
+		return nil, err
+	}
+	product.ID = domain.ProductID(strconv.FormatInt(id, 10))
+	if deletedAt.Valid {
+		product.DeletedAt = &deletedAt.Time
+	}
+	return &product, nil
+}
+
+// parseID maps a domain ID onto the auto-increment product_id column.
+func parseID(id domain.ProductID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, domain.ErrInvalidProductID
+	}
+	return n, nil
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,9 +14,20 @@ import (
 type Config struct {
 	Server struct {
 		Port int
+		// RequestTimeout bounds the context handed to the service layer
+		RequestTimeout time.Duration
+		// ActorHeader names the request header that identifies the caller
+		// in the audit trail
+		ActorHeader string
 	}
 	Database struct {
-		Type  string
+		Type string
+		// ShadowType is a second backend that mirrors the writes to Type
+		ShadowType string
+		// ShadowIDMap is the file pairing product IDs of Type with those of
+		// ShadowType, as written by the transfer command
+		ShadowIDMap string
+
 		MySQL struct {
 			User     string
 			Password string
@@ -22,13 +35,57 @@ type Config struct {
 			Port     int
 			Name     string
 			DSN      string
+			// AutoMigrate applies pending schema migrations on startup
+			AutoMigrate bool
+			// ReplicaDSNs are read replicas that take the plain reads
+			ReplicaDSNs []string
+			// ReplicaCheckInterval is how often replica health is checked
+			ReplicaCheckInterval time.Duration
 		}
 		MongoDB struct {
 			URI        string
 			Database   string
 			Collection string
+			// EnsureIndexes reconciles the collection indexes on startup
+			EnsureIndexes bool
+		}
+		Postgres struct {
+			User     string
+			Password string
+			Host     string
+			Port     int
+			Name     string
+			SSLMode  string
+			DSN      string
+		}
+		SQLite struct {
+			Path string
 		}
 	}
+	Outbox struct {
+		// Enabled records product events and runs the relay
+		Enabled bool
+		// Publisher is where the relay delivers events: log or webhook
+		Publisher    string
+		WebhookURL   string
+		PollInterval time.Duration
+		BatchSize    int
+	}
+	Reservations struct {
+		// TTL is how long a reservation holds stock when the client does
+		// not say
+		TTL time.Duration
+		// SweepInterval is how often expired reservations are released
+		SweepInterval time.Duration
+	}
+	Cache struct {
+		// Enabled serves product lookups by ID from an in-process cache
+		Enabled bool
+		// Size is the most products the cache holds
+		Size int
+		// TTL is how long a cached product is served before it is re-read
+		TTL time.Duration
+	}
 }
 
 func LoadConfigFromEnv() (config Config, err error) {
@@ -48,22 +105,69 @@ func LoadConfigFromEnv() (config Config, err error) {
 		return config, fmt.Errorf("invalid SERVER_PORT value: %v", err)
 	}
 
+	config.Server.RequestTimeout = 30 * time.Second
+	timeoutStr := os.Getenv("SERVER_REQUEST_TIMEOUT")
+	if timeoutStr != "" {
+		config.Server.RequestTimeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			return config, fmt.Errorf("invalid SERVER_REQUEST_TIMEOUT value: %v", err)
+		}
+	}
+
+	config.Server.ActorHeader = os.Getenv("SERVER_ACTOR_HEADER")
+	if config.Server.ActorHeader == "" {
+		config.Server.ActorHeader = "X-Actor"
+	}
+
 	// Get database type
 	config.Database.Type = os.Getenv("DB_TYPE")
 	if config.Database.Type == "" {
 		return config, fmt.Errorf("DB_TYPE environment variable is not set")
 	}
 
-	switch config.Database.Type {
+	err = loadOutboxConfig(&config)
+	if err != nil {
+		return config, err
+	}
+
+	err = loadReservationsConfig(&config)
+	if err != nil {
+		return config, err
+	}
+
+	err = loadCacheConfig(&config)
+	if err != nil {
+		return config, err
+	}
+
+	err = LoadDatabaseConfig(&config, config.Database.Type)
+	if err != nil {
+		return config, err
+	}
+
+	err = loadShadowConfig(&config)
+	return config, err
+}
+
+// LoadDatabaseConfig reads the settings of the backend dbType into config.
+// Commands that work with more than the DB_TYPE backend use it to load the
+// others.
+func LoadDatabaseConfig(config *Config, dbType string) error {
+	switch dbType {
 	case "mysql":
-		err = loadMySQLConfig(&config)
+		return loadMySQLConfig(config)
 	case "mongodb":
-		err = loadMongoDBConfig(&config)
+		return loadMongoDBConfig(config)
+	case "postgres":
+		return loadPostgresConfig(config)
+	case "sqlite":
+		return loadSQLiteConfig(config)
+	case "memory":
+		// The in-memory backend needs no further settings
+		return nil
 	default:
-		return config, fmt.Errorf("unsupported DB_TYPE: %s", config.Database.Type)
+		return fmt.Errorf("unsupported DB_TYPE: %s", dbType)
 	}
-
-	return config, err
 }
 
 func loadMySQLConfig(config *Config) error {
@@ -82,8 +186,31 @@ func loadMySQLConfig(config *Config) error {
 	}
 	config.Database.MySQL.Port = dbPort
 
+	autoMigrateStr := os.Getenv("MYSQL_AUTO_MIGRATE")
+	if autoMigrateStr != "" {
+		config.Database.MySQL.AutoMigrate, err = strconv.ParseBool(autoMigrateStr)
+		if err != nil {
+			return fmt.Errorf("invalid MYSQL_AUTO_MIGRATE value: %v", err)
+		}
+	}
+
+	for _, dsn := range strings.Split(os.Getenv("MYSQL_REPLICA_DSNS"), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			config.Database.MySQL.ReplicaDSNs = append(config.Database.MySQL.ReplicaDSNs, dsn)
+		}
+	}
+
+	config.Database.MySQL.ReplicaCheckInterval = 5 * time.Second
+	checkIntervalStr := os.Getenv("MYSQL_REPLICA_CHECK_INTERVAL")
+	if checkIntervalStr != "" {
+		config.Database.MySQL.ReplicaCheckInterval, err = time.ParseDuration(checkIntervalStr)
+		if err != nil || config.Database.MySQL.ReplicaCheckInterval <= 0 {
+			return fmt.Errorf("invalid MYSQL_REPLICA_CHECK_INTERVAL value: %q", checkIntervalStr)
+		}
+	}
+
 	// Construct the DSN
-	config.Database.MySQL.DSN = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
+	config.Database.MySQL.DSN = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
 		config.Database.MySQL.User,
 		config.Database.MySQL.Password,
 		config.Database.MySQL.Host,
@@ -110,5 +237,168 @@ func loadMongoDBConfig(config *Config) error {
 		return fmt.Errorf("MONGODB_COLLECTION environment variable is not set")
 	}
 
+	config.Database.MongoDB.EnsureIndexes = true
+	ensureIndexesStr := os.Getenv("MONGODB_ENSURE_INDEXES")
+	if ensureIndexesStr != "" {
+		ensureIndexes, err := strconv.ParseBool(ensureIndexesStr)
+		if err != nil {
+			return fmt.Errorf("invalid MONGODB_ENSURE_INDEXES value: %v", err)
+		}
+		config.Database.MongoDB.EnsureIndexes = ensureIndexes
+	}
+
+	return nil
+}
+
+func loadPostgresConfig(config *Config) error {
+	config.Database.Postgres.User = os.Getenv("POSTGRES_USER")
+	config.Database.Postgres.Password = os.Getenv("POSTGRES_PASSWORD")
+	config.Database.Postgres.Host = os.Getenv("POSTGRES_HOST")
+	config.Database.Postgres.Name = os.Getenv("POSTGRES_NAME")
+
+	config.Database.Postgres.SSLMode = os.Getenv("POSTGRES_SSLMODE")
+	if config.Database.Postgres.SSLMode == "" {
+		config.Database.Postgres.SSLMode = "disable"
+	}
+
+	dbPortStr := os.Getenv("POSTGRES_PORT")
+	if dbPortStr == "" {
+		return fmt.Errorf("POSTGRES_PORT environment variable is not set")
+	}
+	dbPort, err := strconv.Atoi(dbPortStr)
+	if err != nil {
+		return fmt.Errorf("invalid POSTGRES_PORT value: %v", err)
+	}
+	config.Database.Postgres.Port = dbPort
+
+	// Construct the DSN
+	config.Database.Postgres.DSN = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		config.Database.Postgres.Host,
+		config.Database.Postgres.Port,
+		config.Database.Postgres.User,
+		config.Database.Postgres.Password,
+		config.Database.Postgres.Name,
+		config.Database.Postgres.SSLMode,
+	)
+
+	return nil
+}
+
+func loadSQLiteConfig(config *Config) error {
+	config.Database.SQLite.Path = os.Getenv("SQLITE_PATH")
+	if config.Database.SQLite.Path == "" {
+		return fmt.Errorf("SQLITE_PATH environment variable is not set")
+	}
+
+	return nil
+}
+
+func loadOutboxConfig(config *Config) error {
+	enabledStr := os.Getenv("OUTBOX_ENABLED")
+	if enabledStr == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(enabledStr)
+	if err != nil {
+		return fmt.Errorf("invalid OUTBOX_ENABLED value: %v", err)
+	}
+	config.Outbox.Enabled = enabled
+
+	config.Outbox.Publisher = os.Getenv("OUTBOX_PUBLISHER")
+	switch config.Outbox.Publisher {
+	case "":
+		config.Outbox.Publisher = "log"
+	case "log":
+	case "webhook":
+		config.Outbox.WebhookURL = os.Getenv("OUTBOX_WEBHOOK_URL")
+		if config.Outbox.WebhookURL == "" {
+			return fmt.Errorf("OUTBOX_WEBHOOK_URL environment variable is not set")
+		}
+	default:
+		return fmt.Errorf("unsupported OUTBOX_PUBLISHER: %s", config.Outbox.Publisher)
+	}
+
+	config.Outbox.PollInterval = time.Second
+	pollIntervalStr := os.Getenv("OUTBOX_POLL_INTERVAL")
+	if pollIntervalStr != "" {
+		config.Outbox.PollInterval, err = time.ParseDuration(pollIntervalStr)
+		if err != nil {
+			return fmt.Errorf("invalid OUTBOX_POLL_INTERVAL value: %v", err)
+		}
+	}
+
+	config.Outbox.BatchSize = 100
+	batchSizeStr := os.Getenv("OUTBOX_BATCH_SIZE")
+	if batchSizeStr != "" {
+		config.Outbox.BatchSize, err = strconv.Atoi(batchSizeStr)
+		if err != nil || config.Outbox.BatchSize < 1 {
+			return fmt.Errorf("invalid OUTBOX_BATCH_SIZE value: %q", batchSizeStr)
+		}
+	}
+
+	return nil
+}
+
+func loadReservationsConfig(config *Config) (err error) {
+	config.Reservations.TTL = 15 * time.Minute
+	ttlStr := os.Getenv("RESERVATION_TTL")
+	if ttlStr != "" {
+		config.Reservations.TTL, err = time.ParseDuration(ttlStr)
+		if err != nil || config.Reservations.TTL <= 0 {
+			return fmt.Errorf("invalid RESERVATION_TTL value: %q", ttlStr)
+		}
+	}
+
+	config.Reservations.SweepInterval = 30 * time.Second
+	sweepIntervalStr := os.Getenv("RESERVATION_SWEEP_INTERVAL")
+	if sweepIntervalStr != "" {
+		config.Reservations.SweepInterval, err = time.ParseDuration(sweepIntervalStr)
+		if err != nil || config.Reservations.SweepInterval <= 0 {
+			return fmt.Errorf("invalid RESERVATION_SWEEP_INTERVAL value: %q", sweepIntervalStr)
+		}
+	}
+
+	return nil
+}
+
+func loadShadowConfig(config *Config) error {
+	config.Database.ShadowType = os.Getenv("DB_SHADOW_TYPE")
+	if config.Database.ShadowType == "" {
+		return nil
+	}
+	if config.Database.ShadowType == config.Database.Type {
+		return fmt.Errorf("DB_SHADOW_TYPE must differ from DB_TYPE")
+	}
+	config.Database.ShadowIDMap = os.Getenv("DB_SHADOW_ID_MAP")
+	return LoadDatabaseConfig(config, config.Database.ShadowType)
+}
+
+func loadCacheConfig(config *Config) (err error) {
+	enabledStr := os.Getenv("CACHE_ENABLED")
+	if enabledStr != "" {
+		config.Cache.Enabled, err = strconv.ParseBool(enabledStr)
+		if err != nil {
+			return fmt.Errorf("invalid CACHE_ENABLED value: %v", err)
+		}
+	}
+
+	config.Cache.Size = 10000
+	sizeStr := os.Getenv("CACHE_SIZE")
+	if sizeStr != "" {
+		config.Cache.Size, err = strconv.Atoi(sizeStr)
+		if err != nil || config.Cache.Size <= 0 {
+			return fmt.Errorf("invalid CACHE_SIZE value: %q", sizeStr)
+		}
+	}
+
+	config.Cache.TTL = 30 * time.Second
+	ttlStr := os.Getenv("CACHE_TTL")
+	if ttlStr != "" {
+		config.Cache.TTL, err = time.ParseDuration(ttlStr)
+		if err != nil || config.Cache.TTL <= 0 {
+			return fmt.Errorf("invalid CACHE_TTL value: %q", ttlStr)
+		}
+	}
+
 	return nil
 }
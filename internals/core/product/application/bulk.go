@@ -0,0 +1,294 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+)
+
+// MaxBulkItems caps the items of one bulk request.
+const MaxBulkItems = 1000
+
+// BulkCreateProducts validates every product and saves the valid ones with
+// a single repository call. With atomic set, one invalid product keeps all
+// of them from being saved; without it, a repository that stops at a
+// failing product keeps the ones before it and the ones after it are
+// saved with another call.
+func (s *ProductService) BulkCreateProducts(ctx context.Context, products []*domain.Product, atomic bool) ([]port.BulkResult, error) {
+	if err := checkBulkSize(len(products)); err != nil {
+		return nil, err
+	}
+
+	results := newBulkResults(len(products))
+	var valid []*domain.Product
+	var indexes []int
+	for i, product := range products {
+		if err := validateProduct(product); err != nil {
+			results[i].Err = err
+			continue
+		}
+		valid = append(valid, product)
+		indexes = append(indexes, i)
+	}
+	if atomic && len(valid) < len(products) {
+		return abortBulk(results), nil
+	}
+
+	saveCtx := ctx
+	if !atomic {
+		// The items stand on their own, so the repository may keep the
+		// ones before a failing item
+		saveCtx = domain.WithPartialWrites(ctx)
+	}
+	var created []int
+	for len(valid) > 0 {
+		err := s.productRepository.SaveProducts(saveCtx, valid)
+		saved := len(valid)
+		var itemErr *domain.BulkItemError
+		if err != nil && (atomic || !errors.As(err, &itemErr) || itemErr.Index < 0 || itemErr.Index >= len(valid)) {
+			for _, i := range indexes {
+				results[i].Err = err
+			}
+			break
+		}
+		if err != nil {
+			saved = itemErr.Index
+			results[indexes[saved]].Err = itemErr.Err
+		}
+		for n, i := range indexes[:saved] {
+			results[i].Product = valid[n]
+			created = append(created, i)
+		}
+		if err == nil {
+			break
+		}
+		valid, indexes = valid[saved+1:], indexes[saved+1:]
+	}
+	for _, i := range created {
+		s.audit(ctx, results[i].Product.ID, domain.AuditCreate, nil, results[i].Product)
+	}
+	return results, nil
+}
+
+// BulkUpdateProducts applies every valid update. Without atomic each
+// product is updated on its own, as UpdateProduct would; with it the
+// updates run as one all-or-nothing repository call.
+func (s *ProductService) BulkUpdateProducts(ctx context.Context, products []*domain.Product, atomic bool) ([]port.BulkResult, error) {
+	if err := checkBulkSize(len(products)); err != nil {
+		return nil, err
+	}
+
+	results := newBulkResults(len(products))
+	for i, product := range products {
+		if err := validateProduct(product); err != nil {
+			results[i].Err = err
+		} else if product.ID.IsZero() {
+			results[i].Err = domain.NewValidationError("id", "product ID is required for update")
+		}
+	}
+
+	if !atomic {
+		for i, product := range products {
+			if results[i].Err != nil {
+				continue
+			}
+			if results[i].Err = s.UpdateProduct(ctx, product); results[i].Err == nil {
+				results[i].Product = product
+			}
+		}
+		return results, nil
+	}
+
+	if failed(results) {
+		return abortBulk(results), nil
+	}
+	befores := make([]*domain.Product, len(products))
+	if s.auditRepository != nil {
+		for i, product := range products {
+			before, err := s.productRepository.FindProductByID(ctx, product.ID)
+			if err != nil {
+				results[i].Err = err
+				return abortBulk(results), nil
+			}
+			befores[i] = before
+		}
+	}
+	if err := s.productRepository.UpdateProducts(ctx, products); err != nil {
+		return failBulk(results, err), nil
+	}
+	for i, product := range products {
+		results[i].Product = product
+		s.audit(ctx, product.ID, domain.AuditUpdate, befores[i], product)
+	}
+	return results, nil
+}
+
+// BulkDeleteProducts moves products to the trash, each on its own like
+// DeleteProduct or, with atomic set, all or none.
+func (s *ProductService) BulkDeleteProducts(ctx context.Context, items []port.BulkDelete, atomic bool) ([]port.BulkResult, error) {
+	if err := checkBulkSize(len(items)); err != nil {
+		return nil, err
+	}
+
+	results := newBulkResults(len(items))
+	for i, item := range items {
+		if item.ID.IsZero() {
+			results[i].Err = domain.NewValidationError("id", "product ID is required for deletion")
+		}
+	}
+
+	if !atomic {
+		for i, item := range items {
+			if results[i].Err == nil {
+				results[i].Err = s.DeleteProduct(ctx, item.ID, item.Version)
+			}
+		}
+		return results, nil
+	}
+
+	if failed(results) {
+		return abortBulk(results), nil
+	}
+	befores := make([]*domain.Product, len(items))
+	if s.auditRepository != nil {
+		for i, item := range items {
+			before, err := s.productRepository.FindProductByID(ctx, item.ID)
+			if err != nil {
+				results[i].Err = err
+				return abortBulk(results), nil
+			}
+			befores[i] = before
+		}
+	}
+	if err := s.productRepository.DeleteProducts(ctx, items); err != nil {
+		return failBulk(results, err), nil
+	}
+	for i, item := range items {
+		s.audit(ctx, item.ID, domain.AuditDelete, befores[i], nil)
+	}
+	return results, nil
+}
+
+func checkBulkSize(n int) error {
+	if n == 0 {
+		return domain.NewValidationError("items", "at least one item is required")
+	}
+	if n > MaxBulkItems {
+		return domain.NewValidationError("items", fmt.Sprintf("at most %d items are allowed", MaxBulkItems))
+	}
+	return nil
+}
+
+func newBulkResults(n int) []port.BulkResult {
+	results := make([]port.BulkResult, n)
+	for i := range results {
+		results[i].Index = i
+	}
+	return results
+}
+
+func failed(results []port.BulkResult) bool {
+	for _, result := range results {
+		if result.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// abortBulk marks every item without an error of its own as not applied.
+func abortBulk(results []port.BulkResult) []port.BulkResult {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = domain.ErrBulkAborted
+		}
+	}
+	return results
+}
+
+// failBulk reports a failed all-or-nothing repository call: the item named
+// by a domain.BulkItemError gets its error and the others are aborted;
+// any other error applies to every item.
+func failBulk(results []port.BulkResult, err error) []port.BulkResult {
+	var itemErr *domain.BulkItemError
+	if errors.As(err, &itemErr) && itemErr.Index >= 0 && itemErr.Index < len(results) {
+		results[itemErr.Index].Err = itemErr.Err
+		return abortBulk(results)
+	}
+	for i := range results {
+		results[i].Err = err
+	}
+	return results
+}
+
+// MaxImportRows caps the rows of one import.
+const MaxImportRows = 10000
+
+// ImportProducts creates the products without an ID and updates the ones
+// with one, each row on its own so that one bad row does not hold up the
+// rest. The rows go through the same rules as CreateProduct and
+// UpdateProduct. With dryRun set nothing is written: the rows are only
+// checked, updates also against the current product and its version.
+func (s *ProductService) ImportProducts(ctx context.Context, products []*domain.Product, dryRun bool) ([]port.BulkResult, error) {
+	if len(products) == 0 {
+		return nil, domain.NewValidationError("rows", "at least one row is required")
+	}
+	if len(products) > MaxImportRows {
+		return nil, domain.NewValidationError("rows", fmt.Sprintf("at most %d rows are allowed", MaxImportRows))
+	}
+
+	results := newBulkResults(len(products))
+	var creates []*domain.Product
+	var indexes []int
+	for i, product := range products {
+		if err := validateProduct(product); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if product.ID.IsZero() {
+			creates = append(creates, product)
+			indexes = append(indexes, i)
+			continue
+		}
+
+		if dryRun {
+			results[i].Err = s.checkUpdate(ctx, product)
+		} else if results[i].Err = s.UpdateProduct(ctx, product); results[i].Err == nil {
+			results[i].Product = product
+		}
+	}
+	if dryRun {
+		return results, nil
+	}
+
+	// Creates go in bulk, which is where an import of new products spends
+	// its time.
+	for start := 0; start < len(creates); start += MaxBulkItems {
+		end := min(start+MaxBulkItems, len(creates))
+		created, err := s.BulkCreateProducts(ctx, creates[start:end], false)
+		if err != nil {
+			return nil, err
+		}
+		for n, result := range created {
+			i := indexes[start+n]
+			results[i].Product = result.Product
+			results[i].Err = result.Err
+		}
+	}
+	return results, nil
+}
+
+// checkUpdate reports whether UpdateProduct would find the product at the
+// version it names.
+func (s *ProductService) checkUpdate(ctx context.Context, product *domain.Product) error {
+	current, err := s.productRepository.FindProductByID(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+	if product.Version != 0 && product.Version != current.Version {
+		return domain.ErrVersionMismatch
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"goproduct/internals/core/product/port"
+	"time"
+)
+
+const (
+	// DefaultRelayBatchSize is the number of events a relay reads at once.
+	DefaultRelayBatchSize = 100
+	// DefaultRelayPollInterval is how long an idle relay waits before
+	// looking for new events.
+	DefaultRelayPollInterval = time.Second
+)
+
+// OutboxRelay delivers the events recorded in an outbox to a publisher,
+// oldest first. Events are marked published only after the publisher
+// accepted them, so a crash in between delivers them again: delivery is at
+// least once.
+type OutboxRelay struct {
+	outbox       port.OutboxRepository
+	publisher    port.EventPublisher
+	batchSize    int
+	pollInterval time.Duration
+	onError      func(error)
+}
+
+// RelayOption configures an OutboxRelay.
+type RelayOption func(*OutboxRelay)
+
+// WithBatchSize sets how many events the relay reads and checkpoints at
+// once.
+func WithBatchSize(size int) RelayOption {
+	return func(r *OutboxRelay) {
+		r.batchSize = size
+	}
+}
+
+// WithPollInterval sets how long the relay waits when the outbox is empty
+// or delivery failed.
+func WithPollInterval(interval time.Duration) RelayOption {
+	return func(r *OutboxRelay) {
+		r.pollInterval = interval
+	}
+}
+
+// WithRelayErrorHandler receives the errors Run recovers from. They are
+// dropped by default.
+func WithRelayErrorHandler(onError func(error)) RelayOption {
+	return func(r *OutboxRelay) {
+		r.onError = onError
+	}
+}
+
+func NewOutboxRelay(outbox port.OutboxRepository, publisher port.EventPublisher, options ...RelayOption) *OutboxRelay {
+	r := &OutboxRelay{
+		outbox:       outbox,
+		publisher:    publisher,
+		batchSize:    DefaultRelayBatchSize,
+		pollInterval: DefaultRelayPollInterval,
+		onError:      func(error) {},
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// Run relays events until ctx is done. A full batch is followed by the
+// next one right away; otherwise the relay sleeps for the poll interval.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	for {
+		published, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.onError(err)
+		}
+
+		wait := r.pollInterval
+		if err == nil && published == r.batchSize {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending events and checkpoints the
+// ones that were delivered. It stops at the first event the publisher
+// rejects, so events are never delivered out of order, and returns the
+// number of events published.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.outbox.PendingEvents(ctx, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("read outbox: %w", err)
+	}
+
+	delivered := make([]string, 0, len(events))
+	var publishErr error
+	for _, event := range events {
+		if publishErr = r.publisher.Publish(ctx, event); publishErr != nil {
+			publishErr = fmt.Errorf("publish event %s: %w", event.ID, publishErr)
+			break
+		}
+		delivered = append(delivered, event.ID)
+	}
+
+	if err := r.outbox.MarkPublished(ctx, delivered); err != nil {
+		return 0, fmt.Errorf("checkpoint outbox: %w", err)
+	}
+	return len(delivered), publishErr
+}
@@ -0,0 +1,267 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"time"
+)
+
+const (
+	// DefaultReservationTTL is how long a reservation holds stock when the
+	// client does not say.
+	DefaultReservationTTL = 15 * time.Minute
+	// MaxReservationTTL caps the hold a client may ask for.
+	MaxReservationTTL = 24 * time.Hour
+	// DefaultSweepInterval is how often a ReservationSweeper looks for
+	// expired reservations.
+	DefaultSweepInterval = 30 * time.Second
+	// sweepBatchSize is the number of expired reservations read at once.
+	sweepBatchSize = 100
+	// SweeperActor is the actor of the changes a ReservationSweeper makes.
+	SweeperActor = "reservation-sweeper"
+)
+
+// errReservationsDisabled is returned by the reservation methods of a
+// service built without WithReservationRepository.
+var errReservationsDisabled = errors.New("stock reservations are not configured")
+
+// WithReservationRepository enables stock reservations, kept in
+// reservations.
+func WithReservationRepository(reservations port.ReservationRepository) Option {
+	return func(s *ProductService) {
+		s.reservations = reservations
+	}
+}
+
+// WithReservationTTL sets how long reservations hold stock when the client
+// does not say.
+func WithReservationTTL(ttl time.Duration) Option {
+	return func(s *ProductService) {
+		s.reservationTTL = ttl
+	}
+}
+
+// ReserveStock moves quantity of a product from its stock into a hold that
+// expires after ttl, or after the default TTL when ttl is zero.
+func (s *ProductService) ReserveStock(ctx context.Context, productID domain.ProductID, quantity int, ttl time.Duration) (*domain.Reservation, error) {
+	if productID.IsZero() {
+		return nil, domain.NewValidationError("id", "product ID is required")
+	}
+	if quantity <= 0 {
+		return nil, domain.NewValidationError("quantity", "quantity must be positive")
+	}
+	if ttl == 0 {
+		ttl = s.reservationTTL
+	}
+	if ttl < 0 || ttl > MaxReservationTTL {
+		return nil, domain.NewValidationError("ttl_seconds", fmt.Sprintf("ttl must be between 1 and %d seconds", int(MaxReservationTTL.Seconds())))
+	}
+	if s.reservations == nil {
+		return nil, errReservationsDisabled
+	}
+
+	now := s.now().UTC()
+	reservation := &domain.Reservation{
+		ID:        domain.NewReservationID(),
+		ProductID: productID,
+		Quantity:  quantity,
+		Status:    domain.ReservationHeld,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.reservations.CreateReservation(ctx, reservation); err != nil {
+		return nil, err
+	}
+	s.recordAudit(ctx, reservationAudit(reservation, ""))
+	return reservation, nil
+}
+
+// CommitReservation turns a hold into a sale: the held quantity leaves the
+// product for good. A hold past its expiry can no longer be committed,
+// even if the sweeper has not released it yet.
+func (s *ProductService) CommitReservation(ctx context.Context, productID domain.ProductID, reservationID string) (*domain.Reservation, error) {
+	return s.settleReservation(ctx, productID, reservationID, domain.ReservationCommitted)
+}
+
+// ReleaseReservation gives the held quantity back to the product's stock.
+func (s *ProductService) ReleaseReservation(ctx context.Context, productID domain.ProductID, reservationID string) (*domain.Reservation, error) {
+	return s.settleReservation(ctx, productID, reservationID, domain.ReservationReleased)
+}
+
+func (s *ProductService) settleReservation(ctx context.Context, productID domain.ProductID, reservationID string, status domain.ReservationStatus) (*domain.Reservation, error) {
+	if productID.IsZero() {
+		return nil, domain.NewValidationError("id", "product ID is required")
+	}
+	if reservationID == "" {
+		return nil, domain.NewValidationError("reservation_id", "reservation ID is required")
+	}
+	if s.reservations == nil {
+		return nil, errReservationsDisabled
+	}
+
+	reservation, err := s.reservations.FindReservation(ctx, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.ProductID != productID {
+		return nil, domain.ErrReservationNotFound
+	}
+
+	now := s.now().UTC()
+	if status == domain.ReservationCommitted && reservation.Status == domain.ReservationHeld && !now.Before(reservation.ExpiresAt) {
+		expired, err := s.reservations.SettleReservation(ctx, reservationID, domain.ReservationExpired, now)
+		if err != nil {
+			return nil, err
+		}
+		s.recordAudit(ctx, reservationAudit(expired, domain.ReservationHeld))
+		return nil, domain.ErrReservationNotHeld
+	}
+	settled, err := s.reservations.SettleReservation(ctx, reservationID, status, now)
+	if err != nil {
+		return nil, err
+	}
+	s.recordAudit(ctx, reservationAudit(settled, domain.ReservationHeld))
+	return settled, nil
+}
+
+// reservationAudit describes a reservation that moved to its current
+// status from the one given, which is empty for a new reservation.
+func reservationAudit(reservation *domain.Reservation, from domain.ReservationStatus) *domain.AuditEntry {
+	entry := &domain.AuditEntry{
+		ProductID: reservation.ProductID,
+		Action:    domain.AuditSettleReservation,
+		Reason:    reservation.ID,
+		Changes: map[string]domain.FieldChange{
+			"status": {From: string(from), To: string(reservation.Status)},
+		},
+	}
+	if from == "" {
+		entry.Action = domain.AuditReserve
+		entry.Changes = map[string]domain.FieldChange{
+			"status":   {From: nil, To: string(reservation.Status)},
+			"quantity": {From: nil, To: reservation.Quantity},
+		}
+	}
+	return entry
+}
+
+// ReservationSweeper releases reservations that ran past their expiry,
+// putting the held quantity back into stock.
+type ReservationSweeper struct {
+	reservations port.ReservationRepository
+	audit        port.AuditRepository
+	interval     time.Duration
+	now          func() time.Time
+	onError      func(error)
+}
+
+// SweeperOption configures a ReservationSweeper.
+type SweeperOption func(*ReservationSweeper)
+
+// WithSweepInterval sets how long the sweeper waits between sweeps.
+func WithSweepInterval(interval time.Duration) SweeperOption {
+	return func(s *ReservationSweeper) {
+		s.interval = interval
+	}
+}
+
+// WithSweeperClock replaces the clock that decides which reservations
+// have expired.
+func WithSweeperClock(now func() time.Time) SweeperOption {
+	return func(s *ReservationSweeper) {
+		s.now = now
+	}
+}
+
+// WithSweeperAuditRepository records every reservation the sweeper
+// expires in audit, attributed to SweeperActor.
+func WithSweeperAuditRepository(audit port.AuditRepository) SweeperOption {
+	return func(s *ReservationSweeper) {
+		s.audit = audit
+	}
+}
+
+// WithSweeperErrorHandler receives the errors Run recovers from, and those
+// of audit entries that could not be recorded. They are dropped by
+// default.
+func WithSweeperErrorHandler(onError func(error)) SweeperOption {
+	return func(s *ReservationSweeper) {
+		s.onError = onError
+	}
+}
+
+func NewReservationSweeper(reservations port.ReservationRepository, options ...SweeperOption) *ReservationSweeper {
+	s := &ReservationSweeper{
+		reservations: reservations,
+		interval:     DefaultSweepInterval,
+		now:          time.Now,
+		onError:      func(error) {},
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Run sweeps until ctx is done. A full batch is followed by the next one
+// right away; otherwise the sweeper sleeps for the interval.
+func (s *ReservationSweeper) Run(ctx context.Context) {
+	for {
+		expired, err := s.SweepOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.onError(err)
+		}
+
+		wait := s.interval
+		if err == nil && expired == sweepBatchSize {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// SweepOnce expires one batch of reservations and returns how many it
+// expired. Reservations settled concurrently by a client are skipped.
+func (s *ReservationSweeper) SweepOnce(ctx context.Context) (int, error) {
+	ctx = domain.WithActor(ctx, SweeperActor)
+	now := s.now().UTC()
+	reservations, err := s.reservations.ExpiredReservations(ctx, now, sweepBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("read expired reservations: %w", err)
+	}
+
+	expired := 0
+	for _, reservation := range reservations {
+		settled, err := s.reservations.SettleReservation(ctx, reservation.ID, domain.ReservationExpired, now)
+		if errors.Is(err, domain.ErrReservationNotHeld) {
+			continue
+		}
+		if err != nil {
+			return expired, fmt.Errorf("expire reservation %s: %w", reservation.ID, err)
+		}
+		expired++
+		s.recordAudit(ctx, reservationAudit(settled, domain.ReservationHeld), now)
+	}
+	return expired, nil
+}
+
+// recordAudit records entry for the sweeper, like
+// ProductService.recordAudit.
+func (s *ReservationSweeper) recordAudit(ctx context.Context, entry *domain.AuditEntry, at time.Time) {
+	if s.audit == nil {
+		return
+	}
+
+	entry.Actor = domain.ActorFrom(ctx)
+	entry.At = at
+	if err := s.audit.RecordAudit(ctx, entry); err != nil {
+		s.onError(fmt.Errorf("%s of product %s applied but not audited: %w", entry.Action, entry.ProductID, err))
+	}
+}
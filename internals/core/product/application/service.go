@@ -1,64 +1,325 @@
 package application
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"goproduct/internals/core/product/domain"
 	"goproduct/internals/core/product/port"
+	"strings"
+	"time"
 )
 
+const (
+	// DefaultPageSize is used when a list request does not set a limit.
+	DefaultPageSize = 20
+	// MaxPageSize caps the limit a client may ask for.
+	MaxPageSize = 100
+)
+
+// snapshotAttempts bounds how often an unconditional update or delete is
+// pinned to the version it read before it is applied as is.
+const snapshotAttempts = 3
+
 // ProductService implements the ports.ProductService interface
 type ProductService struct {
 	productRepository port.ProductRepository
+	auditRepository   port.AuditRepository
+	onAuditError      func(error)
+	reservations      port.ReservationRepository
+	reservationTTL    time.Duration
+	now               func() time.Time
 }
 
 // Ensure ProductService implements the interface
 var _ port.ProductService = (*ProductService)(nil)
 
+// Option configures optional ProductService collaborators.
+type Option func(*ProductService)
+
+// WithAuditRepository records every change made through the service in
+// audit. Without it changes go unrecorded and histories are empty.
+func WithAuditRepository(audit port.AuditRepository) Option {
+	return func(s *ProductService) {
+		s.auditRepository = audit
+	}
+}
+
+// WithAuditErrorHandler receives the errors of audit entries that could
+// not be recorded. The change they describe stands either way, so they are
+// dropped by default.
+func WithAuditErrorHandler(onAuditError func(error)) Option {
+	return func(s *ProductService) {
+		s.onAuditError = onAuditError
+	}
+}
+
+// WithClock replaces the clock used to timestamp audit entries and
+// reservations.
+func WithClock(now func() time.Time) Option {
+	return func(s *ProductService) {
+		s.now = now
+	}
+}
+
 // NewProductService creates a new ProductService instance
-func NewProductService(repository port.ProductRepository) *ProductService {
-	return &ProductService{
+func NewProductService(repository port.ProductRepository, options ...Option) *ProductService {
+	s := &ProductService{
 		productRepository: repository,
+		onAuditError:      func(error) {},
+		reservationTTL:    DefaultReservationTTL,
+		now:               time.Now,
+	}
+	for _, option := range options {
+		option(s)
 	}
+	return s
 }
 
 // Example service methods (you'll need to implement the actual logic):
 
 // CreateProduct creates a new product
-func (s *ProductService) CreateProduct(product *domain.Product) error {
-	// You might add validation here (e.g., check for required fields, uniqueness, etc.)
-	if product.ProductName == "" {
-		return errors.New("product name are required")
+func (s *ProductService) CreateProduct(ctx context.Context, product *domain.Product) error {
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	if err := s.productRepository.SaveProduct(ctx, product); err != nil {
+		return err
 	}
-	return s.productRepository.SaveProduct(product)
+	s.audit(ctx, product.ID, domain.AuditCreate, nil, product)
+	return nil
 }
 
 // GetProductByID retrieves a product by its ID
-func (s *ProductService) GetProductByID(productID interface{}) (*domain.Product, error) {
-	if productID == nil {
-		return nil, errors.New("product ID is required")
+func (s *ProductService) GetProductByID(ctx context.Context, productID domain.ProductID) (*domain.Product, error) {
+	if productID.IsZero() {
+		return nil, domain.NewValidationError("id", "product ID is required")
+	}
+
+	return s.productRepository.FindProductByID(ctx, productID)
+}
+func (s *ProductService) GetAllProducts(ctx context.Context) ([]*domain.Product, error) {
+	return s.productRepository.GetAllProducts(ctx)
+}
+
+// StreamProducts calls fn with every live product as the repository reads
+// it.
+func (s *ProductService) StreamProducts(ctx context.Context, fn func(*domain.Product) error) error {
+	return s.productRepository.StreamProducts(ctx, fn)
+}
+
+// ListProducts returns one page of products, applying the default and
+// maximum page sizes.
+func (s *ProductService) ListProducts(ctx context.Context, query port.ProductQuery) (*port.Page, error) {
+	switch {
+	case query.Limit < 0:
+		return nil, domain.NewValidationError("limit", "limit must not be negative")
+	case query.Limit == 0:
+		query.Limit = DefaultPageSize
+	case query.Limit > MaxPageSize:
+		query.Limit = MaxPageSize
 	}
 
-	return s.productRepository.FindProductByID(productID)
+	return s.productRepository.ListProducts(ctx, query)
 }
-func (s *ProductService) GetAllProducts() ([]*domain.Product, error) {
-	return s.productRepository.GetAllProducts()
+
+// SearchProducts runs a relevance-ranked full-text search on product names.
+func (s *ProductService) SearchProducts(ctx context.Context, query string, limit int) ([]*domain.Product, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, domain.NewValidationError("q", "search query is required")
+	}
+	switch {
+	case limit < 0:
+		return nil, domain.NewValidationError("limit", "limit must not be negative")
+	case limit == 0:
+		limit = DefaultPageSize
+	case limit > MaxPageSize:
+		limit = MaxPageSize
+	}
+
+	return s.productRepository.SearchProducts(ctx, query, limit)
 }
 
 // UpdateProduct updates an existing product
-func (s *ProductService) UpdateProduct(product *domain.Product) error {
-	// You might add validation here and ensure the product exists before updating
-	if product.ID == nil {
-		return errors.New("product ID is required for update")
+func (s *ProductService) UpdateProduct(ctx context.Context, product *domain.Product) error {
+	if product.ID.IsZero() {
+		return domain.NewValidationError("id", "product ID is required for update")
+	}
+	if err := validateProduct(product); err != nil {
+		return err
 	}
 
-	return s.productRepository.UpdateProduct(product)
+	version := product.Version
+	before, err := s.writeSnapshot(ctx, product.ID, version, func(version int64) error {
+		product.Version = version
+		return s.productRepository.UpdateProduct(ctx, product)
+	})
+	if err != nil {
+		product.Version = version
+		return err
+	}
+	s.audit(ctx, product.ID, domain.AuditUpdate, before, product)
+	return nil
+}
+
+// AdjustStock adds delta to the stock of a product without a
+// read-modify-write, so concurrent adjustments never lose an update. The
+// reason is kept in the audit trail.
+func (s *ProductService) AdjustStock(ctx context.Context, productID domain.ProductID, delta int, reason domain.StockReason) (*domain.Product, error) {
+	if productID.IsZero() {
+		return nil, domain.NewValidationError("id", "product ID is required")
+	}
+	if delta == 0 {
+		return nil, domain.NewValidationError("delta", "delta must not be zero")
+	}
+	if !reason.Valid() {
+		return nil, domain.NewValidationError("reason", fmt.Sprintf("reason must be one of %v", domain.StockReasons))
+	}
+
+	product, err := s.productRepository.AdjustStock(ctx, productID, delta)
+	if err != nil {
+		return nil, err
+	}
+	s.recordAudit(ctx, &domain.AuditEntry{
+		ProductID: productID,
+		Action:    domain.AuditAdjustStock,
+		Reason:    string(reason),
+		Changes:   map[string]domain.FieldChange{"stock": {From: product.Stock - delta, To: product.Stock}},
+	})
+	return product, nil
 }
 
-// DeleteProduct deletes a product by its ID
-func (s *ProductService) DeleteProduct(productID interface{}) error {
-	if productID == nil {
-		return errors.New("product ID is required for deletion")
+// DeleteProduct moves a product to the trash. A non-zero version makes the
+// delete conditional on the product not having changed since.
+func (s *ProductService) DeleteProduct(ctx context.Context, productID domain.ProductID, version int64) error {
+	if productID.IsZero() {
+		return domain.NewValidationError("id", "product ID is required for deletion")
 	}
 
-	return s.productRepository.DeleteProduct(productID)
+	before, err := s.writeSnapshot(ctx, productID, version, func(version int64) error {
+		return s.productRepository.DeleteProduct(ctx, productID, version)
+	})
+	if err != nil {
+		return err
+	}
+	s.audit(ctx, productID, domain.AuditDelete, before, nil)
+	return nil
+}
+
+// writeSnapshot reads the product and runs write with the version to
+// write at, returning the product as it was right before the write. The
+// version is the one the caller asked for; an unconditional write is
+// pinned to the version just read, so that no other write can slip in
+// between, and is read and tried again when it loses that race. After
+// snapshotAttempts it is applied unconditionally, and the snapshot may
+// then be slightly stale. Without an audit repository nobody needs the
+// snapshot, so write runs once at the version asked for.
+func (s *ProductService) writeSnapshot(ctx context.Context, productID domain.ProductID, version int64, write func(version int64) error) (*domain.Product, error) {
+	if s.auditRepository == nil {
+		return nil, write(version)
+	}
+	for attempt := 1; ; attempt++ {
+		before, err := s.productRepository.FindProductByID(ctx, productID)
+		if err != nil {
+			return nil, err
+		}
+		pinned := version
+		if version == 0 && attempt < snapshotAttempts {
+			pinned = before.Version
+		}
+		err = write(pinned)
+		if version == 0 && pinned != 0 && errors.Is(err, domain.ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return before, nil
+	}
+}
+
+// RestoreProduct takes a product out of the trash and returns it as it is
+// now.
+func (s *ProductService) RestoreProduct(ctx context.Context, productID domain.ProductID) (*domain.Product, error) {
+	if productID.IsZero() {
+		return nil, domain.NewValidationError("id", "product ID is required for restore")
+	}
+
+	if err := s.productRepository.RestoreProduct(ctx, productID); err != nil {
+		return nil, err
+	}
+	product, err := s.productRepository.FindProductByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, productID, domain.AuditRestore, nil, product)
+	return product, nil
+}
+
+// PurgeProduct permanently removes a product, live or in the trash.
+func (s *ProductService) PurgeProduct(ctx context.Context, productID domain.ProductID) error {
+	if productID.IsZero() {
+		return domain.NewValidationError("id", "product ID is required for purge")
+	}
+
+	if err := s.productRepository.PurgeProduct(ctx, productID); err != nil {
+		return err
+	}
+	s.audit(ctx, productID, domain.AuditPurge, nil, nil)
+	return nil
+}
+
+// ProductHistory returns the recorded changes of a product, oldest first.
+// The history of a deleted or purged product stays available.
+func (s *ProductService) ProductHistory(ctx context.Context, productID domain.ProductID) ([]*domain.AuditEntry, error) {
+	if productID.IsZero() {
+		return nil, domain.NewValidationError("id", "product ID is required")
+	}
+	if s.auditRepository == nil {
+		return []*domain.AuditEntry{}, nil
+	}
+
+	return s.auditRepository.ListAudit(ctx, productID)
+}
+
+// audit records a change made by the actor in ctx. The change itself has
+// already been applied and stands even when it cannot be recorded, so a
+// failure goes to the audit error handler rather than to the caller.
+func (s *ProductService) audit(ctx context.Context, productID domain.ProductID, action domain.AuditAction, before, after *domain.Product) {
+	s.recordAudit(ctx, &domain.AuditEntry{
+		ProductID: productID,
+		Action:    action,
+		Changes:   domain.Diff(before, after),
+	})
+}
+
+// recordAudit stamps entry with the actor in ctx and the current time and
+// records it, like audit.
+func (s *ProductService) recordAudit(ctx context.Context, entry *domain.AuditEntry) {
+	if s.auditRepository == nil {
+		return
+	}
+
+	entry.Actor = domain.ActorFrom(ctx)
+	entry.At = s.now().UTC()
+	if err := s.auditRepository.RecordAudit(ctx, entry); err != nil {
+		s.onAuditError(fmt.Errorf("%s of product %s applied but not audited: %w", entry.Action, entry.ProductID, err))
+	}
+}
+
+// validateProduct checks the fields shared by create and update.
+func validateProduct(product *domain.Product) error {
+	if product == nil {
+		return domain.NewValidationError("product", "product is required")
+	}
+	if product.ProductName == "" {
+		return domain.NewValidationError("product_name", "product name is required")
+	}
+	if product.Price < 0 {
+		return domain.NewValidationError("price", "price must not be negative")
+	}
+	if product.Stock < 0 {
+		return domain.NewValidationError("stock", "stock must not be negative")
+	}
+	return nil
 }
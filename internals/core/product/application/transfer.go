@@ -0,0 +1,182 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"strconv"
+)
+
+// DefaultTransferBatchSize is the number of products a transfer copies at
+// once.
+const DefaultTransferBatchSize = 500
+
+// TransferCheckpoint records how far a transfer got. Passing the last one
+// saved back to Run resumes after the last batch it covers.
+type TransferCheckpoint struct {
+	// Cursor is where the next batch starts in the source listing.
+	Cursor string `json:"cursor"`
+	Copied int    `json:"copied"`
+	Done   bool   `json:"done"`
+}
+
+// IDMapping pairs the ID a product had in the source with the one the
+// target gave it.
+type IDMapping struct {
+	Source domain.ProductID
+	Target domain.ProductID
+}
+
+// TransferSummary describes the live products of one repository. The
+// checksum covers name, price and stock and does not depend on order or
+// IDs, so it matches between backends holding the same catalog.
+type TransferSummary struct {
+	Count    int    `json:"count"`
+	Checksum string `json:"checksum"`
+}
+
+// TransferReport compares source and target after a transfer.
+type TransferReport struct {
+	Source TransferSummary `json:"source"`
+	Target TransferSummary `json:"target"`
+	Match  bool            `json:"match"`
+}
+
+// ProductTransfer copies the live products of one repository into
+// another, in batches ordered by source ID. The target assigns new IDs and
+// versions; reservations, the stock they hold, history and the trash stay
+// behind. A batch and the checkpoint after it are not written atomically,
+// so a crash in between copies that batch again on resume, which Verify
+// then reports.
+type ProductTransfer struct {
+	source       port.ProductRepository
+	target       port.ProductRepository
+	batchSize    int
+	onCheckpoint func(TransferCheckpoint) error
+	onBatch      func([]IDMapping) error
+}
+
+// TransferOption configures a ProductTransfer.
+type TransferOption func(*ProductTransfer)
+
+// WithTransferBatchSize sets how many products are read and written at
+// once.
+func WithTransferBatchSize(size int) TransferOption {
+	return func(t *ProductTransfer) {
+		t.batchSize = size
+	}
+}
+
+// WithCheckpointSaver receives the checkpoint after every batch, once the
+// batch is written. Run stops if it fails.
+func WithCheckpointSaver(save func(TransferCheckpoint) error) TransferOption {
+	return func(t *ProductTransfer) {
+		t.onCheckpoint = save
+	}
+}
+
+// WithIDMappings receives the old and new IDs of every batch before its
+// checkpoint is saved. Run stops if it fails.
+func WithIDMappings(record func([]IDMapping) error) TransferOption {
+	return func(t *ProductTransfer) {
+		t.onBatch = record
+	}
+}
+
+func NewProductTransfer(source, target port.ProductRepository, options ...TransferOption) *ProductTransfer {
+	t := &ProductTransfer{
+		source:       source,
+		target:       target,
+		batchSize:    DefaultTransferBatchSize,
+		onCheckpoint: func(TransferCheckpoint) error { return nil },
+		onBatch:      func([]IDMapping) error { return nil },
+	}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
+// Run copies the products after checkpoint and returns the final
+// checkpoint, which is Done once the source is exhausted.
+func (t *ProductTransfer) Run(ctx context.Context, checkpoint TransferCheckpoint) (TransferCheckpoint, error) {
+	for !checkpoint.Done {
+		page, err := t.source.ListProducts(ctx, port.ProductQuery{
+			Sort:   []port.SortKey{{Field: port.SortByID}},
+			Limit:  t.batchSize,
+			Cursor: checkpoint.Cursor,
+		})
+		if err != nil {
+			return checkpoint, fmt.Errorf("read batch after %d products: %w", checkpoint.Copied, err)
+		}
+
+		if len(page.Products) > 0 {
+			copies := make([]*domain.Product, len(page.Products))
+			for i, product := range page.Products {
+				copies[i] = &domain.Product{
+					ProductName: product.ProductName,
+					Price:       product.Price,
+					Stock:       product.Stock,
+				}
+			}
+			// A batch is written whole or not at all, so a resume from the
+			// last checkpoint never duplicates part of it
+			if err := t.target.SaveProducts(ctx, copies); err != nil {
+				return checkpoint, fmt.Errorf("write batch after %d products: %w", checkpoint.Copied, err)
+			}
+
+			mappings := make([]IDMapping, len(copies))
+			for i := range copies {
+				mappings[i] = IDMapping{Source: page.Products[i].ID, Target: copies[i].ID}
+			}
+			if err := t.onBatch(mappings); err != nil {
+				return checkpoint, err
+			}
+		}
+
+		checkpoint.Cursor = page.NextCursor
+		checkpoint.Copied += len(page.Products)
+		checkpoint.Done = page.NextCursor == ""
+		if err := t.onCheckpoint(checkpoint); err != nil {
+			return checkpoint, err
+		}
+	}
+	return checkpoint, nil
+}
+
+// Verify summarizes both repositories and reports whether they hold the
+// same products.
+func (t *ProductTransfer) Verify(ctx context.Context) (*TransferReport, error) {
+	source, err := summarize(ctx, t.source)
+	if err != nil {
+		return nil, fmt.Errorf("summarize source: %w", err)
+	}
+	target, err := summarize(ctx, t.target)
+	if err != nil {
+		return nil, fmt.Errorf("summarize target: %w", err)
+	}
+	return &TransferReport{Source: source, Target: target, Match: source == target}, nil
+}
+
+// summarize adds up a hash of every product, which keeps the checksum
+// independent of order while still catching duplicates.
+func summarize(ctx context.Context, repository port.ProductRepository) (TransferSummary, error) {
+	var summary TransferSummary
+	var sum uint64
+	err := repository.StreamProducts(ctx, func(product *domain.Product) error {
+		hash := sha256.Sum256([]byte(product.ProductName + "\x1f" +
+			strconv.FormatFloat(product.Price, 'f', -1, 64) + "\x1f" +
+			strconv.Itoa(product.Stock)))
+		sum += binary.BigEndian.Uint64(hash[:8])
+		summary.Count++
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+	summary.Checksum = fmt.Sprintf("%016x", sum)
+	return summary, nil
+}
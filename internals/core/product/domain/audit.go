@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// AuditAction names the kind of change an AuditEntry records.
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+	AuditPurge   AuditAction = "purge"
+	// AuditAdjustStock is a relative stock change, recorded with the
+	// StockReason in AuditEntry.Reason.
+	AuditAdjustStock AuditAction = "adjust_stock"
+	// AuditReserve and AuditSettleReservation move stock through a
+	// reservation, whose ID is recorded in AuditEntry.Reason.
+	AuditReserve           AuditAction = "reserve"
+	AuditSettleReservation AuditAction = "settle_reservation"
+)
+
+// AnonymousActor is recorded when a change carries no actor.
+const AnonymousActor = "anonymous"
+
+// FieldChange is the value of one product field before and after a
+// change. From is nil for a field that did not exist before, To for one
+// that no longer exists after.
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// AuditEntry records who changed a product, when, and how.
+type AuditEntry struct {
+	ID        string                 `json:"id"`
+	ProductID ProductID              `json:"product_id"`
+	Action    AuditAction            `json:"action"`
+	Actor     string                 `json:"actor"`
+	At        time.Time              `json:"at"`
+	Reason    string                 `json:"reason,omitempty"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+}
+
+// Diff lists the fields that differ between two states of a product,
+// keyed by their JSON names. A nil before or after stands for a product
+// that does not exist on that side, so every field is reported.
+func Diff(before, after *Product) map[string]FieldChange {
+	fields := func(product *Product) map[string]any {
+		if product == nil {
+			return map[string]any{}
+		}
+		return map[string]any{
+			"product_name": product.ProductName,
+			"price":        product.Price,
+			"stock":        product.Stock,
+		}
+	}
+
+	from, to := fields(before), fields(after)
+	changes := make(map[string]FieldChange)
+	for _, name := range []string{"product_name", "price", "stock"} {
+		if from[name] != to[name] {
+			changes[name] = FieldChange{From: from[name], To: to[name]}
+		}
+	}
+	return changes
+}
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes changes to actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, or AnonymousActor.
+func ActorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
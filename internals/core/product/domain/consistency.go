@@ -0,0 +1,34 @@
+package domain
+
+import "context"
+
+type primaryReadKey struct{}
+
+// WithPrimaryRead returns a context whose reads must see every write
+// acknowledged so far, so repositories with read replicas serve them from
+// the primary.
+func WithPrimaryRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadKey{}, true)
+}
+
+// PrimaryRead reports whether ctx was marked by WithPrimaryRead.
+func PrimaryRead(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadKey{}).(bool)
+	return primary
+}
+
+type partialWritesKey struct{}
+
+// WithPartialWrites returns a context whose bulk writes need not be all or
+// nothing. A repository may then stop at the first failing item, keeping
+// the items before it, which saves MongoDB a transaction and with it the
+// need for a replica set.
+func WithPartialWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, partialWritesKey{}, true)
+}
+
+// PartialWrites reports whether ctx was marked by WithPartialWrites.
+func PartialWrites(ctx context.Context) bool {
+	partial, _ := ctx.Value(partialWritesKey{}).(bool)
+	return partial
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors shared by the service and every repository adapter.
+// Callers classify failures with errors.Is rather than by message.
+var (
+	ErrNotFound   = errors.New("product not found")
+	ErrValidation = errors.New("invalid product")
+	ErrConflict   = errors.New("product conflict")
+)
+
+// ErrInvalidProductID is returned by repositories when an ID cannot be
+// mapped onto the backend's native key type.
+var ErrInvalidProductID = fmt.Errorf("%w: invalid product ID", ErrValidation)
+
+// ErrVersionMismatch is returned when an update or delete names a version
+// that is no longer current because someone else changed the product.
+var ErrVersionMismatch = fmt.Errorf("%w: product was modified concurrently", ErrConflict)
+
+// ValidationError reports a rejected field. It matches ErrValidation.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{Field: field, Message: message}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// ErrInsufficientStock is returned when a reservation or a stock
+// adjustment asks for more than the available stock.
+var ErrInsufficientStock error = &classifiedError{class: ErrConflict, message: "insufficient stock"}
+
+// ErrReservationNotFound is returned for an unknown reservation.
+var ErrReservationNotFound error = &classifiedError{class: ErrNotFound, message: "reservation not found"}
+
+// ErrReservationNotHeld is returned when committing or releasing a
+// reservation that was already committed, released or has expired.
+var ErrReservationNotHeld error = &classifiedError{class: ErrConflict, message: "reservation is no longer held"}
+
+// classifiedError is a sentinel with a message of its own that still
+// matches one of the broad sentinels above.
+type classifiedError struct {
+	class   error
+	message string
+}
+
+func (e *classifiedError) Error() string {
+	return e.message
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.class
+}
+
+// ErrBulkAborted is reported for the items of an all-or-nothing bulk
+// request that were not applied because another item failed.
+var ErrBulkAborted = errors.New("not applied because another item failed")
+
+// BulkItemError attributes an error to the item at Index of a bulk
+// request. It unwraps to that error.
+type BulkItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BulkItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *BulkItemError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// EventType names a kind of product change announced to other systems.
+type EventType string
+
+const (
+	ProductCreated  EventType = "product.created"
+	ProductUpdated  EventType = "product.updated"
+	ProductDeleted  EventType = "product.deleted"
+	ProductRestored EventType = "product.restored"
+	ProductPurged   EventType = "product.purged"
+)
+
+// ProductEvent announces a committed product change. Product holds the
+// state after a create or update and is nil otherwise; consumers that
+// need the state after other changes read it back.
+type ProductEvent struct {
+	ID         string    `json:"id"`
+	Type       EventType `json:"type"`
+	ProductID  ProductID `json:"product_id"`
+	Product    *Product  `json:"product,omitempty"`
+	Actor      string    `json:"actor"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// NewProductEvent describes a change made by the actor in ctx. The ID is
+// assigned when the event is stored.
+func NewProductEvent(ctx context.Context, eventType EventType, productID ProductID, product *Product) *ProductEvent {
+	event := &ProductEvent{
+		Type:       eventType,
+		ProductID:  productID,
+		Actor:      ActorFrom(ctx),
+		OccurredAt: time.Now().UTC(),
+	}
+	if product != nil {
+		snapshot := *product
+		event.Product = &snapshot
+	}
+	return event
+}
@@ -1,8 +1,34 @@
 package domain
 
+import "time"
+
+// ProductID identifies a product independently of the storage backend.
+// Each repository adapter owns the conversion to and from its native key
+// (an auto-increment integer, a MongoDB ObjectID, ...), so the value is
+// opaque everywhere else and always serializes as a JSON string.
+type ProductID string
+
+func (id ProductID) String() string {
+	return string(id)
+}
+
+// IsZero reports whether the ID has not been assigned yet.
+func (id ProductID) IsZero() bool {
+	return id == ""
+}
+
 type Product struct {
-	ID          interface{} `json:"id" bson:"_id,omitempty"`
-	ProductName string      `json:"product_name" bson:"productname"`
-	Price       float64     `json:"price" bson:"price"`
-	Stock       int         `json:"stock" bson:"stock"`
+	ID          ProductID `json:"id"`
+	ProductName string    `json:"product_name"`
+	Price       float64   `json:"price"`
+	Stock       int       `json:"stock"`
+	// Reserved is the quantity held by reservations, on top of Stock.
+	// Only reservations change it.
+	Reserved int `json:"reserved"`
+	// Version starts at 1 and is incremented by every update. Updates and
+	// deletes that name a version only succeed while it is still current.
+	Version int64 `json:"version"`
+	// DeletedAt is set while the product sits in the trash. Deleted
+	// products are left out of every read except the trash listing.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// ReservationStatus is the lifecycle state of a Reservation. Only a held
+// reservation can change state, and only once.
+type ReservationStatus string
+
+const (
+	// ReservationHeld keeps the quantity out of the available stock.
+	ReservationHeld ReservationStatus = "held"
+	// ReservationCommitted means the held quantity was sold.
+	ReservationCommitted ReservationStatus = "committed"
+	// ReservationReleased means the held quantity went back into stock.
+	ReservationReleased ReservationStatus = "released"
+	// ReservationExpired is a hold that was released because it ran past
+	// its expiry.
+	ReservationExpired ReservationStatus = "expired"
+)
+
+// Reservation holds a quantity of a product, typically while a customer
+// pays for it. While held the quantity is counted in Product.Reserved
+// instead of Product.Stock.
+type Reservation struct {
+	ID        string            `json:"id"`
+	ProductID ProductID         `json:"product_id"`
+	Quantity  int               `json:"quantity"`
+	Status    ReservationStatus `json:"status"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	SettledAt *time.Time        `json:"settled_at,omitempty"`
+}
+
+// NewReservationID returns a random opaque reservation ID. Reservation IDs
+// are generated up front so that every backend stores them the same way.
+func NewReservationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
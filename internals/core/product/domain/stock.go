@@ -0,0 +1,25 @@
+package domain
+
+// StockReason says why the stock of a product was adjusted.
+type StockReason string
+
+const (
+	StockRestock    StockReason = "restock"
+	StockSale       StockReason = "sale"
+	StockReturn     StockReason = "return"
+	StockDamage     StockReason = "damage"
+	StockCorrection StockReason = "correction"
+)
+
+// StockReasons lists the accepted reasons, in documentation order.
+var StockReasons = []StockReason{StockRestock, StockSale, StockReturn, StockDamage, StockCorrection}
+
+// Valid reports whether r is one of StockReasons.
+func (r StockReason) Valid() bool {
+	for _, reason := range StockReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package port
+
+import "goproduct/internals/core/product/domain"
+
+// BulkResult is the outcome of one item of a bulk request, at the same
+// Index as the item. Err is nil when the item was applied; Product then
+// holds its ID and new version, except after a delete.
+type BulkResult struct {
+	Index   int
+	Product *domain.Product
+	Err     error
+}
+
+// BulkDelete names a product to delete in a bulk request. A zero Version
+// deletes unconditionally.
+type BulkDelete struct {
+	ID      domain.ProductID
+	Version int64
+}
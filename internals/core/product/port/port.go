@@ -1,27 +1,135 @@
 package port
 
 import (
+	"context"
 	"goproduct/internals/core/product/domain"
+	"time"
 
 	fiber "github.com/gofiber/fiber/v2"
 )
 
 // ProductService defines the interface for interacting with Product entities
 type ProductService interface {
-	CreateProduct(product *domain.Product) error
-	GetProductByID(productID interface{}) (*domain.Product, error)
-	UpdateProduct(product *domain.Product) error
-	DeleteProduct(productID interface{}) error
-	GetAllProducts() ([]*domain.Product, error)
+	CreateProduct(ctx context.Context, product *domain.Product) error
+	GetProductByID(ctx context.Context, productID domain.ProductID) (*domain.Product, error)
+	UpdateProduct(ctx context.Context, product *domain.Product) error
+	AdjustStock(ctx context.Context, productID domain.ProductID, delta int, reason domain.StockReason) (*domain.Product, error)
+	DeleteProduct(ctx context.Context, productID domain.ProductID, version int64) error
+	// The bulk methods report every item in a BulkResult. With atomic set
+	// they apply all items or none, and fail as a whole only when the
+	// request itself is unacceptable.
+	BulkCreateProducts(ctx context.Context, products []*domain.Product, atomic bool) ([]BulkResult, error)
+	BulkUpdateProducts(ctx context.Context, products []*domain.Product, atomic bool) ([]BulkResult, error)
+	BulkDeleteProducts(ctx context.Context, items []BulkDelete, atomic bool) ([]BulkResult, error)
+	// ImportProducts creates the products without an ID and updates the
+	// others, reporting every row like the bulk methods. With dryRun set it
+	// only checks the rows.
+	ImportProducts(ctx context.Context, products []*domain.Product, dryRun bool) ([]BulkResult, error)
+	GetAllProducts(ctx context.Context) ([]*domain.Product, error)
+	StreamProducts(ctx context.Context, fn func(*domain.Product) error) error
+	ListProducts(ctx context.Context, query ProductQuery) (*Page, error)
+	SearchProducts(ctx context.Context, query string, limit int) ([]*domain.Product, error)
+	RestoreProduct(ctx context.Context, productID domain.ProductID) (*domain.Product, error)
+	PurgeProduct(ctx context.Context, productID domain.ProductID) error
+	ProductHistory(ctx context.Context, productID domain.ProductID) ([]*domain.AuditEntry, error)
+	ReserveStock(ctx context.Context, productID domain.ProductID, quantity int, ttl time.Duration) (*domain.Reservation, error)
+	CommitReservation(ctx context.Context, productID domain.ProductID, reservationID string) (*domain.Reservation, error)
+	ReleaseReservation(ctx context.Context, productID domain.ProductID, reservationID string) (*domain.Reservation, error)
 }
 
 // ProductRepository defines the interface for data access related to Products
 type ProductRepository interface {
-	SaveProduct(product *domain.Product) error
-	FindProductByID(id interface{}) (*domain.Product, error)
-	UpdateProduct(product *domain.Product) error
-	DeleteProduct(id interface{}) error
-	GetAllProducts() ([]*domain.Product, error)
+	SaveProduct(ctx context.Context, product *domain.Product) error
+	FindProductByID(ctx context.Context, id domain.ProductID) (*domain.Product, error)
+	// UpdateProduct replaces the product if product.Version is still
+	// current, or unconditionally when it is zero, and stores the new
+	// version in product.Version. A stale version yields
+	// domain.ErrVersionMismatch.
+	UpdateProduct(ctx context.Context, product *domain.Product) error
+	// AdjustStock adds delta, which may be negative, to the stock of a
+	// live product in one atomic step and returns the product as it is
+	// now. It yields domain.ErrInsufficientStock rather than take the
+	// stock below zero.
+	AdjustStock(ctx context.Context, id domain.ProductID, delta int) (*domain.Product, error)
+	// DeleteProduct moves the product to the trash if version is still
+	// current, or unconditionally when it is zero. Products in the trash
+	// count as not found everywhere but ListProducts with Filter.Deleted.
+	DeleteProduct(ctx context.Context, id domain.ProductID, version int64) error
+	// RestoreProduct takes a product out of the trash. It yields
+	// domain.ErrNotFound unless the product is in the trash.
+	RestoreProduct(ctx context.Context, id domain.ProductID) error
+	// PurgeProduct permanently removes a product, whether or not it is in
+	// the trash.
+	PurgeProduct(ctx context.Context, id domain.ProductID) error
+	// SaveProducts inserts all products or none of them, assigning IDs
+	// and versions as SaveProduct does. Under domain.WithPartialWrites it
+	// may instead save the products in order up to a failing one, which it
+	// reports as a *domain.BulkItemError.
+	SaveProducts(ctx context.Context, products []*domain.Product) error
+	// UpdateProducts applies UpdateProduct to every product, all or none.
+	// The first failure rolls back the others and is returned as a
+	// *domain.BulkItemError.
+	UpdateProducts(ctx context.Context, products []*domain.Product) error
+	// DeleteProducts applies DeleteProduct to every item, all or none,
+	// failing like UpdateProducts.
+	DeleteProducts(ctx context.Context, items []BulkDelete) error
+	GetAllProducts(ctx context.Context) ([]*domain.Product, error)
+	// StreamProducts calls fn with every live product in ID order as it
+	// is read, without collecting them first, and stops at the first error
+	// fn returns, returning it.
+	StreamProducts(ctx context.Context, fn func(*domain.Product) error) error
+	// ListProducts returns at most query.Limit products matching the
+	// filter, in the requested order, starting after the cursor.
+	ListProducts(ctx context.Context, query ProductQuery) (*Page, error)
+	// SearchProducts runs a full-text search on the product name and
+	// returns at most limit products, best match first.
+	SearchProducts(ctx context.Context, query string, limit int) ([]*domain.Product, error)
+}
+
+// AuditRepository stores the change history of products. Entries outlive
+// the products they describe, so a purged product keeps its history.
+type AuditRepository interface {
+	// RecordAudit appends an entry and assigns its ID.
+	RecordAudit(ctx context.Context, entry *domain.AuditEntry) error
+	// ListAudit returns every entry for a product, oldest first.
+	ListAudit(ctx context.Context, productID domain.ProductID) ([]*domain.AuditEntry, error)
+}
+
+// ReservationRepository holds stock for reservations. Every method moves
+// quantity between Product.Stock and Product.Reserved and changes the
+// reservation in one atomic step, bumping the product version.
+type ReservationRepository interface {
+	// CreateReservation takes reservation.Quantity out of the stock of a
+	// live product and stores the held reservation. It yields
+	// domain.ErrInsufficientStock when the stock is short.
+	CreateReservation(ctx context.Context, reservation *domain.Reservation) error
+	FindReservation(ctx context.Context, id string) (*domain.Reservation, error)
+	// SettleReservation moves a held reservation to status at the given
+	// time. Committing drops the held quantity; releasing or expiring puts
+	// it back into stock. It yields domain.ErrReservationNotHeld when the
+	// reservation was already settled.
+	SettleReservation(ctx context.Context, id string, status domain.ReservationStatus, at time.Time) (*domain.Reservation, error)
+	// ExpiredReservations returns up to limit held reservations that
+	// expired before now, oldest expiry first.
+	ExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*domain.Reservation, error)
+}
+
+// OutboxRepository reads the product events that repositories record in
+// the same transaction as the change itself, for relaying to an
+// EventPublisher.
+type OutboxRepository interface {
+	// PendingEvents returns up to limit events that have not been marked
+	// published, oldest first.
+	PendingEvents(ctx context.Context, limit int) ([]*domain.ProductEvent, error)
+	// MarkPublished records that the events with the given IDs have been
+	// delivered, so they are not returned again.
+	MarkPublished(ctx context.Context, ids []string) error
+}
+
+// EventPublisher delivers product events to downstream systems. Delivery
+// is at least once, so consumers must tolerate duplicates.
+type EventPublisher interface {
+	Publish(ctx context.Context, event *domain.ProductEvent) error
 }
 
 // ProductHandlers defines the interface for handling HTTP requests related to Products
@@ -29,6 +137,21 @@ type ProductHandlers interface {
 	CreateProduct(c *fiber.Ctx) error
 	GetProduct(c *fiber.Ctx) error
 	UpdateProduct(c *fiber.Ctx) error
+	AdjustStock(c *fiber.Ctx) error
 	DeleteProduct(c *fiber.Ctx) error
+	BulkCreateProducts(c *fiber.Ctx) error
+	BulkUpdateProducts(c *fiber.Ctx) error
+	BulkDeleteProducts(c *fiber.Ctx) error
+	ExportProducts(c *fiber.Ctx) error
+	ImportProducts(c *fiber.Ctx) error
 	GetAllProducts(c *fiber.Ctx) error
+	StreamProducts(c *fiber.Ctx) error
+	SearchProducts(c *fiber.Ctx) error
+	ListTrash(c *fiber.Ctx) error
+	RestoreProduct(c *fiber.Ctx) error
+	PurgeProduct(c *fiber.Ctx) error
+	ProductHistory(c *fiber.Ctx) error
+	CreateReservation(c *fiber.Ctx) error
+	CommitReservation(c *fiber.Ctx) error
+	ReleaseReservation(c *fiber.Ctx) error
 }
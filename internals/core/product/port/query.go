@@ -0,0 +1,205 @@
+package port
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"goproduct/internals/core/product/domain"
+	"strings"
+)
+
+// SortField names a product attribute the list can be ordered by.
+type SortField string
+
+const (
+	SortByID    SortField = "id"
+	SortByName  SortField = "product_name"
+	SortByPrice SortField = "price"
+	SortByStock SortField = "stock"
+)
+
+// SortKey orders the list by one field.
+type SortKey struct {
+	Field      SortField
+	Descending bool
+}
+
+// ProductFilter narrows the product list. Nil bounds and an empty
+// NameContains leave the corresponding attribute unconstrained.
+type ProductFilter struct {
+	PriceMin     *float64
+	PriceMax     *float64
+	StockLT      *int
+	NameContains string
+	// Deleted lists the trash instead of the live products.
+	Deleted bool
+}
+
+// ProductQuery is the backend-neutral description of a product listing:
+// which products to include, how to order them and which page to return.
+// Every ordering is made total by a final ascending ID key, which is what
+// keeps keyset pagination stable.
+type ProductQuery struct {
+	Filter ProductFilter
+	Sort   []SortKey
+	// Limit is the maximum number of products to return.
+	Limit int
+	// Cursor is the NextCursor of the previous page, or empty for the
+	// first page.
+	Cursor string
+}
+
+// Page is a slice of the product list plus the cursor of the next page.
+// NextCursor is empty once the last page has been reached.
+type Page struct {
+	Products   []*domain.Product
+	NextCursor string
+}
+
+// Cursor is the decoded form of ProductQuery.Cursor: the position of the
+// last product on the previous page. Repositories resume right after it.
+type Cursor struct {
+	AfterID domain.ProductID `json:"after"`
+	// Sort is the canonical sort the cursor was issued for.
+	Sort  string  `json:"sort,omitempty"`
+	Name  string  `json:"name,omitempty"`
+	Price float64 `json:"price,omitempty"`
+	Stock int     `json:"stock,omitempty"`
+}
+
+// IsZero reports whether the cursor points at the start of the list.
+func (c Cursor) IsZero() bool {
+	return c.AfterID.IsZero()
+}
+
+// Value returns the cursor position for a sort field.
+func (c Cursor) Value(field SortField) any {
+	switch field {
+	case SortByName:
+		return c.Name
+	case SortByPrice:
+		return c.Price
+	case SortByStock:
+		return c.Stock
+	default:
+		return c.AfterID
+	}
+}
+
+// ParseSort parses a comma separated sort specification such as
+// "price,-stock", where a leading minus sorts descending.
+func ParseSort(spec string) ([]SortKey, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var keys []SortKey
+	seen := make(map[SortField]bool)
+	for _, part := range strings.Split(spec, ",") {
+		key := SortKey{Field: SortField(strings.TrimSpace(part))}
+		if strings.HasPrefix(string(key.Field), "-") {
+			key.Field = key.Field[1:]
+			key.Descending = true
+		}
+		switch key.Field {
+		case SortByID, SortByName, SortByPrice, SortByStock:
+		default:
+			return nil, domain.NewValidationError("sort", "cannot sort by "+string(key.Field))
+		}
+		if seen[key.Field] {
+			return nil, domain.NewValidationError("sort", "duplicate sort field "+string(key.Field))
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// FormatSort is the inverse of ParseSort.
+func FormatSort(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = string(key.Field)
+		if key.Descending {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// OrderKeys returns the sort keys with the ID tiebreaker appended. Keys
+// after an explicit ID key are dropped, since the ID is already unique.
+func (q ProductQuery) OrderKeys() []SortKey {
+	var keys []SortKey
+	for _, key := range q.Sort {
+		keys = append(keys, key)
+		if key.Field == SortByID {
+			return keys
+		}
+	}
+	return append(keys, SortKey{Field: SortByID})
+}
+
+// Decode validates the query and decodes its cursor. Repositories call it
+// before building their query.
+func (q ProductQuery) Decode() (Cursor, error) {
+	if q.Limit < 1 {
+		return Cursor{}, domain.NewValidationError("limit", "limit must be positive")
+	}
+	if q.Filter.PriceMin != nil && q.Filter.PriceMax != nil && *q.Filter.PriceMin > *q.Filter.PriceMax {
+		return Cursor{}, domain.NewValidationError("price_min", "price_min must not exceed price_max")
+	}
+
+	cursor, err := DecodeCursor(q.Cursor)
+	if err != nil {
+		return cursor, err
+	}
+	if !cursor.IsZero() && cursor.Sort != FormatSort(q.Sort) {
+		return cursor, domain.NewValidationError("cursor", "cursor was issued for a different sort")
+	}
+	return cursor, nil
+}
+
+// NewPage builds a Page from up to Limit+1 products. The extra product,
+// if present, only signals that another page follows and is dropped.
+func (q ProductQuery) NewPage(products []*domain.Product) *Page {
+	if len(products) <= q.Limit {
+		return &Page{Products: products}
+	}
+
+	products = products[:q.Limit]
+	last := products[q.Limit-1]
+	return &Page{
+		Products: products,
+		NextCursor: EncodeCursor(Cursor{
+			AfterID: last.ID,
+			Sort:    FormatSort(q.Sort),
+			Name:    last.ProductName,
+			Price:   last.Price,
+			Stock:   last.Stock,
+		}),
+	}
+}
+
+// EncodeCursor turns a cursor into the opaque token handed to clients.
+func EncodeCursor(cursor Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by EncodeCursor. An empty token
+// decodes to the zero Cursor.
+func DecodeCursor(token string) (Cursor, error) {
+	var cursor Cursor
+	if token == "" {
+		return cursor, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, domain.NewValidationError("cursor", "invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.IsZero() {
+		return cursor, domain.NewValidationError("cursor", "invalid cursor")
+	}
+	return cursor, nil
+}
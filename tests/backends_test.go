@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/adapter/repository/mongodb_repository"
+	"goproduct/internals/adapter/repository/mysql_repository"
+	"goproduct/internals/adapter/repository/postgres_repository"
+	"goproduct/internals/adapter/repository/sqlite_repository"
+	"goproduct/internals/core/product/port"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// backends returns a constructor for every repository that can run
+// without an external database, keyed by name. Each call to a constructor
+// yields an empty repository.
+func backends() map[string]func(t *testing.T) port.ProductRepository {
+	return map[string]func(t *testing.T) port.ProductRepository{
+		"memory": func(t *testing.T) port.ProductRepository {
+			return memory_repository.NewProductRepository()
+		},
+		"sqlite": func(t *testing.T) port.ProductRepository {
+			repo, err := sqlite_repository.NewProductRepository(filepath.Join(t.TempDir(), "products.db"))
+			require.NoError(t, err)
+			return repo
+		},
+	}
+}
+
+// databaseBackends returns a constructor for every repository backed by a
+// database server. A constructor skips the test unless its environment
+// variable names a server to use; the products it finds there are purged
+// before and after the test, so point it at a disposable database.
+func databaseBackends() map[string]func(t *testing.T) port.ProductRepository {
+	return map[string]func(t *testing.T) port.ProductRepository{
+		"mysql": func(t *testing.T) port.ProductRepository {
+			repo, err := mysql_repository.NewProductRepository(requireEnv(t, "TEST_MYSQL_DSN"))
+			require.NoError(t, err)
+			migrator, err := repo.Migrator()
+			require.NoError(t, err)
+			_, err = migrator.Up(context.Background())
+			require.NoError(t, err)
+			return emptied(t, repo)
+		},
+		"postgres": func(t *testing.T) port.ProductRepository {
+			repo, err := postgres_repository.NewProductRepository(requireEnv(t, "TEST_POSTGRES_DSN"))
+			require.NoError(t, err)
+			return emptied(t, repo)
+		},
+		"mongodb": func(t *testing.T) port.ProductRepository {
+			// Every test gets a collection of its own
+			collection := fmt.Sprintf("products_test_%d", time.Now().UnixNano())
+			repo, err := mongodb_repository.NewProductRepository(requireEnv(t, "TEST_MONGODB_URI"), "goproduct_test", collection)
+			require.NoError(t, err)
+			_, err = repo.EnsureIndexes(context.Background())
+			require.NoError(t, err)
+			return emptied(t, repo)
+		},
+	}
+}
+
+func requireEnv(t *testing.T, name string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		t.Skipf("%s is not set", name)
+	}
+	return value
+}
+
+// emptied purges every product from repo, live or in the trash, now and
+// when the test ends.
+func emptied(t *testing.T, repo port.ProductRepository) port.ProductRepository {
+	purge := func() {
+		ctx := context.Background()
+		for _, deleted := range []bool{false, true} {
+			for {
+				page, err := repo.ListProducts(ctx, port.ProductQuery{Filter: port.ProductFilter{Deleted: deleted}, Limit: 100})
+				require.NoError(t, err)
+				if len(page.Products) == 0 {
+					break
+				}
+				for _, product := range page.Products {
+					require.NoError(t, repo.PurgeProduct(ctx, product.ID))
+				}
+			}
+		}
+	}
+	purge()
+	t.Cleanup(purge)
+	return repo
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"context"
+	"goproduct/internals/adapter/repository/cache_repository"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRepository counts the reads that reach the backend and, when
+// gate is set, holds each of them until it is closed.
+type countingRepository struct {
+	port.ProductRepository
+	reads   atomic.Int32
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (r *countingRepository) FindProductByID(ctx context.Context, id domain.ProductID) (*domain.Product, error) {
+	r.reads.Add(1)
+	if r.gate != nil {
+		r.started <- struct{}{}
+		<-r.gate
+	}
+	return r.ProductRepository.FindProductByID(ctx, id)
+}
+
+func TestCacheRepository(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T, capacity int, opts ...cache_repository.Option) (*cache_repository.ProductRepository, *countingRepository, []*domain.Product) {
+		memory := memory_repository.NewProductRepository()
+		products := make([]*domain.Product, 3)
+		for i := range products {
+			products[i] = &domain.Product{ProductName: "Item", Price: float64(i + 1), Stock: 5}
+			require.NoError(t, memory.SaveProduct(ctx, products[i]))
+		}
+		inner := &countingRepository{ProductRepository: memory}
+		return cache_repository.NewProductRepository(inner, capacity, time.Minute, opts...), inner, products
+	}
+
+	t.Run("serves repeated reads from the cache", func(t *testing.T) {
+		cache, inner, products := setup(t, 10)
+
+		first, err := cache.FindProductByID(ctx, products[0].ID)
+		require.NoError(t, err)
+		first.ProductName = "Changed by the caller"
+
+		second, err := cache.FindProductByID(ctx, products[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Item", second.ProductName)
+		assert.Equal(t, int32(1), inner.reads.Load())
+		assert.Equal(t, cache_repository.Stats{Hits: 1, Misses: 1, Size: 1}, cache.Stats())
+
+		_, err = cache.FindProductByID(ctx, "404")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("expires entries after the TTL", func(t *testing.T) {
+		now := time.Now()
+		cache, inner, products := setup(t, 10, cache_repository.WithClock(func() time.Time { return now }))
+
+		_, err := cache.FindProductByID(ctx, products[0].ID)
+		require.NoError(t, err)
+		now = now.Add(time.Minute)
+		_, err = cache.FindProductByID(ctx, products[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), inner.reads.Load())
+	})
+
+	t.Run("evicts the least recently used product", func(t *testing.T) {
+		cache, inner, products := setup(t, 2)
+
+		for _, i := range []int{0, 1, 0, 2, 0, 1} {
+			_, err := cache.FindProductByID(ctx, products[i].ID)
+			require.NoError(t, err)
+		}
+		// 0 and 1 miss, 0 hits, 2 evicts 1, 0 hits, 1 misses again
+		assert.Equal(t, int32(4), inner.reads.Load())
+		assert.Equal(t, uint64(2), cache.Stats().Evictions)
+	})
+
+	t.Run("drops products written through it", func(t *testing.T) {
+		cache, _, products := setup(t, 10)
+		service := application.NewProductService(cache)
+
+		_, err := cache.FindProductByID(ctx, products[0].ID)
+		require.NoError(t, err)
+		require.NoError(t, service.UpdateProduct(ctx, &domain.Product{ID: products[0].ID, ProductName: "Renamed", Price: 1, Stock: 5}))
+		found, err := cache.FindProductByID(ctx, products[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", found.ProductName)
+
+		_, err = service.AdjustStock(ctx, products[0].ID, 2, domain.StockRestock)
+		require.NoError(t, err)
+		found, err = cache.FindProductByID(ctx, products[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, 7, found.Stock)
+
+		require.NoError(t, service.DeleteProduct(ctx, products[0].ID, 0))
+		_, err = cache.FindProductByID(ctx, products[0].ID)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("drops products whose stock a reservation moves", func(t *testing.T) {
+		memory := memory_repository.NewProductRepository()
+		product := &domain.Product{ProductName: "Item", Price: 1, Stock: 5}
+		require.NoError(t, memory.SaveProduct(ctx, product))
+		cache := cache_repository.NewProductRepository(memory, 10, time.Minute)
+		service := application.NewProductService(cache,
+			application.WithReservationRepository(cache.Reservations(memory.Reservations())))
+
+		_, err := cache.FindProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		reservation, err := service.ReserveStock(ctx, product.ID, 2, 0)
+		require.NoError(t, err)
+		found, err := cache.FindProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, found.Stock)
+
+		_, err = service.ReleaseReservation(ctx, product.ID, reservation.ID)
+		require.NoError(t, err)
+		found, err = cache.FindProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5, found.Stock)
+	})
+
+	t.Run("coalesces concurrent misses", func(t *testing.T) {
+		cache, inner, products := setup(t, 10)
+		inner.started = make(chan struct{}, 1)
+		inner.gate = make(chan struct{})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				found, err := cache.FindProductByID(ctx, products[0].ID)
+				assert.NoError(t, err)
+				assert.Equal(t, products[0].ID, found.ID)
+			}()
+		}
+		<-inner.started
+		// Give the other readers time to join the read in flight
+		time.Sleep(50 * time.Millisecond)
+		close(inner.gate)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), inner.reads.Load())
+		assert.Equal(t, uint64(10), cache.Stats().Misses)
+	})
+
+	t.Run("does not cache a read that raced a write", func(t *testing.T) {
+		cache, inner, products := setup(t, 10)
+		inner.started = make(chan struct{}, 1)
+		inner.gate = make(chan struct{})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, err := cache.FindProductByID(ctx, products[0].ID)
+			assert.NoError(t, err)
+		}()
+		<-inner.started
+		cache.Invalidate(products[0].ID)
+		close(inner.gate)
+		<-done
+
+		inner.gate = nil
+		_, err := cache.FindProductByID(ctx, products[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), inner.reads.Load())
+	})
+
+	t.Run("returns when the caller gives up", func(t *testing.T) {
+		cache, inner, products := setup(t, 10)
+		inner.started = make(chan struct{}, 1)
+		inner.gate = make(chan struct{})
+		defer close(inner.gate)
+
+		ctx, cancel := context.WithCancel(ctx)
+		go func() {
+			<-inner.started
+			cancel()
+		}()
+		_, err := cache.FindProductByID(ctx, products[0].ID)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
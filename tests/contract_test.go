@@ -0,0 +1,281 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"goproduct/internals/adapter/repository/cache_repository"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/adapter/repository/shadow_repository"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryContract(t *testing.T) {
+	all := backends()
+	for name, newRepository := range databaseBackends() {
+		all[name] = newRepository
+	}
+	// The decorators must be indistinguishable from what they wrap
+	all["cache"] = func(t *testing.T) port.ProductRepository {
+		return cache_repository.NewProductRepository(backends()["sqlite"](t), 100, time.Minute)
+	}
+	all["shadow"] = func(t *testing.T) port.ProductRepository {
+		return shadow_repository.NewProductRepository(backends()["sqlite"](t), memory_repository.NewProductRepository())
+	}
+
+	for name, newRepository := range all {
+		t.Run(name, func(t *testing.T) {
+			runRepositoryContract(t, newRepository)
+		})
+	}
+}
+
+// runRepositoryContract checks the behaviour every port.ProductRepository
+// shares. newRepository must return an empty repository on every call.
+func runRepositoryContract(t *testing.T, newRepository func(t *testing.T) port.ProductRepository) {
+	ctx := context.Background()
+
+	t.Run("save and find", func(t *testing.T) {
+		repo := newRepository(t)
+
+		product := &domain.Product{ProductName: "Lamp", Price: 12.5, Stock: 3}
+		require.NoError(t, repo.SaveProduct(ctx, product))
+		require.NotEmpty(t, product.ID)
+		assert.Equal(t, int64(1), product.Version)
+
+		// The ID must survive a trip through its string form, as it does
+		// in URLs and CSV files
+		found, err := repo.FindProductByID(ctx, domain.ProductID(product.ID.String()))
+		require.NoError(t, err)
+		assert.Equal(t, product.ID, found.ID)
+		assert.Equal(t, "Lamp", found.ProductName)
+		assert.Equal(t, 12.5, found.Price)
+		assert.Equal(t, 3, found.Stock)
+		assert.Zero(t, found.Reserved)
+		assert.Equal(t, int64(1), found.Version)
+		assert.Nil(t, found.DeletedAt)
+
+		other := &domain.Product{ProductName: "Mug", Price: 4}
+		require.NoError(t, repo.SaveProduct(ctx, other))
+		assert.NotEqual(t, product.ID, other.ID)
+	})
+
+	t.Run("list in ID order", func(t *testing.T) {
+		repo := newRepository(t)
+
+		var ids []domain.ProductID
+		for _, name := range []string{"Lamp", "Mug", "Desk"} {
+			product := &domain.Product{ProductName: name, Price: 1}
+			require.NoError(t, repo.SaveProduct(ctx, product))
+			ids = append(ids, product.ID)
+		}
+
+		all, err := repo.GetAllProducts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ids, productIDs(all))
+
+		var streamed []*domain.Product
+		require.NoError(t, repo.StreamProducts(ctx, func(product *domain.Product) error {
+			streamed = append(streamed, product)
+			return nil
+		}))
+		assert.Equal(t, ids, productIDs(streamed))
+
+		var listed []*domain.Product
+		query := port.ProductQuery{Limit: 2}
+		for {
+			page, err := repo.ListProducts(ctx, query)
+			require.NoError(t, err)
+			listed = append(listed, page.Products...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		assert.Equal(t, ids, productIDs(listed))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := newRepository(t)
+		product := &domain.Product{ProductName: "Lamp", Price: 10, Stock: 1}
+		require.NoError(t, repo.SaveProduct(ctx, product))
+
+		update := &domain.Product{ID: product.ID, ProductName: "Desk lamp", Price: 12, Stock: 2, Version: 1}
+		require.NoError(t, repo.UpdateProduct(ctx, update))
+		assert.Equal(t, int64(2), update.Version)
+
+		found, err := repo.FindProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Desk lamp", found.ProductName)
+		assert.Equal(t, 12.0, found.Price)
+		assert.Equal(t, 2, found.Stock)
+		assert.Equal(t, int64(2), found.Version)
+
+		// Writing the same values again still counts as an update
+		same := &domain.Product{ID: product.ID, ProductName: "Desk lamp", Price: 12, Stock: 2, Version: 2}
+		require.NoError(t, repo.UpdateProduct(ctx, same))
+		assert.Equal(t, int64(3), same.Version)
+
+		stale := &domain.Product{ID: product.ID, ProductName: "Old lamp", Price: 1, Version: 1}
+		assert.ErrorIs(t, repo.UpdateProduct(ctx, stale), domain.ErrVersionMismatch)
+
+		unconditional := &domain.Product{ID: product.ID, ProductName: "Any lamp", Price: 1}
+		require.NoError(t, repo.UpdateProduct(ctx, unconditional))
+		assert.Equal(t, int64(4), unconditional.Version)
+	})
+
+	t.Run("delete and restore", func(t *testing.T) {
+		repo := newRepository(t)
+		product := &domain.Product{ProductName: "Lamp", Price: 10}
+		require.NoError(t, repo.SaveProduct(ctx, product))
+
+		assert.ErrorIs(t, repo.DeleteProduct(ctx, product.ID, 5), domain.ErrVersionMismatch)
+		require.NoError(t, repo.DeleteProduct(ctx, product.ID, 1))
+
+		_, err := repo.FindProductByID(ctx, product.ID)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteProduct(ctx, product.ID, 0), domain.ErrNotFound)
+		assert.ErrorIs(t, repo.UpdateProduct(ctx, &domain.Product{ID: product.ID, ProductName: "Lamp"}), domain.ErrNotFound)
+		all, err := repo.GetAllProducts(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, all)
+
+		trash, err := repo.ListProducts(ctx, port.ProductQuery{Filter: port.ProductFilter{Deleted: true}, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, trash.Products, 1)
+		assert.Equal(t, product.ID, trash.Products[0].ID)
+		assert.NotNil(t, trash.Products[0].DeletedAt)
+
+		require.NoError(t, repo.RestoreProduct(ctx, product.ID))
+		assert.ErrorIs(t, repo.RestoreProduct(ctx, product.ID), domain.ErrNotFound)
+		found, err := repo.FindProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Nil(t, found.DeletedAt)
+
+		require.NoError(t, repo.PurgeProduct(ctx, product.ID))
+		_, err = repo.FindProductByID(ctx, product.ID)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.ErrorIs(t, repo.PurgeProduct(ctx, product.ID), domain.ErrNotFound)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		repo := newRepository(t)
+		// An ID the backend issued once, for a product that is gone
+		product := &domain.Product{ProductName: "Lamp", Price: 10, Stock: 1}
+		require.NoError(t, repo.SaveProduct(ctx, product))
+		require.NoError(t, repo.PurgeProduct(ctx, product.ID))
+		missing := product.ID
+
+		_, err := repo.FindProductByID(ctx, missing)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.ErrorIs(t, repo.UpdateProduct(ctx, &domain.Product{ID: missing, ProductName: "Lamp", Version: 1}), domain.ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteProduct(ctx, missing, 0), domain.ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteProduct(ctx, missing, 1), domain.ErrNotFound)
+		assert.ErrorIs(t, repo.RestoreProduct(ctx, missing), domain.ErrNotFound)
+		_, err = repo.AdjustStock(ctx, missing, 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+
+		// An ID no backend could have issued is invalid rather than missing
+		_, err = repo.FindProductByID(ctx, "not an id")
+		assert.ErrorIs(t, err, domain.ErrInvalidProductID)
+		assert.ErrorIs(t, repo.DeleteProduct(ctx, "not an id", 0), domain.ErrInvalidProductID)
+	})
+
+	t.Run("adjust stock", func(t *testing.T) {
+		repo := newRepository(t)
+		product := &domain.Product{ProductName: "Lamp", Price: 10, Stock: 2}
+		require.NoError(t, repo.SaveProduct(ctx, product))
+
+		adjusted, err := repo.AdjustStock(ctx, product.ID, 3)
+		require.NoError(t, err)
+		assert.Equal(t, 5, adjusted.Stock)
+		assert.Equal(t, int64(2), adjusted.Version)
+
+		_, err = repo.AdjustStock(ctx, product.ID, -6)
+		assert.ErrorIs(t, err, domain.ErrInsufficientStock)
+		found, err := repo.FindProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5, found.Stock)
+	})
+
+	t.Run("concurrent updates", func(t *testing.T) {
+		repo := newRepository(t)
+		product := &domain.Product{ProductName: "Lamp", Price: 10}
+		require.NoError(t, repo.SaveProduct(ctx, product))
+
+		// Every writer read version 1, so exactly one of them may win
+		const writers = 8
+		errs := make(chan error, writers)
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- repo.UpdateProduct(ctx, &domain.Product{ID: product.ID, ProductName: "Lamp", Price: float64(i), Version: 1})
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		won := 0
+		for err := range errs {
+			if err == nil {
+				won++
+			} else {
+				assert.ErrorIs(t, err, domain.ErrVersionMismatch)
+			}
+		}
+		assert.Equal(t, 1, won)
+		found, err := repo.FindProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), found.Version)
+	})
+
+	t.Run("concurrent stock adjustments", func(t *testing.T) {
+		repo := newRepository(t)
+		product := &domain.Product{ProductName: "Lamp", Price: 10, Stock: 5}
+		require.NoError(t, repo.SaveProduct(ctx, product))
+
+		const buyers = 12
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var left []int
+		for i := 0; i < buyers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				adjusted, err := repo.AdjustStock(ctx, product.ID, -1)
+				if errors.Is(err, domain.ErrInsufficientStock) {
+					return
+				}
+				if assert.NoError(t, err) {
+					mu.Lock()
+					left = append(left, adjusted.Stock)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		// Each sale reports the stock its own adjustment left
+		assert.ElementsMatch(t, []int{4, 3, 2, 1, 0}, left)
+		found, err := repo.FindProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Zero(t, found.Stock)
+		assert.Equal(t, int64(6), found.Version)
+	})
+}
+
+func productIDs(products []*domain.Product) []domain.ProductID {
+	ids := make([]domain.ProductID, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	return ids
+}
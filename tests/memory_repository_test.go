@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("assigns IDs and round-trips products", func(t *testing.T) {
+		repo := memory_repository.NewProductRepository()
+
+		product := &domain.Product{ProductName: "Keyboard", Price: 49.5, Stock: 3}
+		assert.NoError(t, repo.SaveProduct(ctx, product))
+		assert.Equal(t, domain.ProductID("1"), product.ID)
+
+		found, err := repo.FindProductByID(ctx, "1")
+		assert.NoError(t, err)
+		assert.Equal(t, product, found)
+
+		found.Stock = 7
+		assert.NoError(t, repo.UpdateProduct(ctx, found))
+
+		products, err := repo.GetAllProducts(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, products, 1)
+		assert.Equal(t, 7, products[0].Stock)
+
+		assert.NoError(t, repo.DeleteProduct(ctx, "1", 0))
+		_, err = repo.FindProductByID(ctx, "1")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("reports missing products", func(t *testing.T) {
+		repo := memory_repository.NewProductRepository()
+
+		err := repo.UpdateProduct(ctx, &domain.Product{ID: "42", ProductName: "Ghost"})
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteProduct(ctx, "42", 0), domain.ErrNotFound)
+	})
+
+	t.Run("pages through products with a cursor", func(t *testing.T) {
+		repo := memory_repository.NewProductRepository()
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, repo.SaveProduct(ctx, &domain.Product{ProductName: "Mouse"}))
+		}
+
+		var seen []domain.ProductID
+		query := port.ProductQuery{Limit: 2}
+		for {
+			result, err := repo.ListProducts(ctx, query)
+			assert.NoError(t, err)
+			for _, product := range result.Products {
+				seen = append(seen, product.ID)
+			}
+			if result.NextCursor == "" {
+				break
+			}
+			query.Cursor = result.NextCursor
+		}
+		assert.Equal(t, []domain.ProductID{"1", "2", "3", "4", "5"}, seen)
+
+		_, err := repo.ListProducts(ctx, port.ProductQuery{Limit: 2, Cursor: "not-a-cursor"})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+
+	t.Run("is safe for concurrent writers", func(t *testing.T) {
+		repo := memory_repository.NewProductRepository()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, repo.SaveProduct(ctx, &domain.Product{ProductName: "Cable"}))
+			}()
+		}
+		wg.Wait()
+
+		products, err := repo.GetAllProducts(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, products, 50)
+	})
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goproduct/internals/adapter/publisher"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/adapter/repository/sqlite_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	netHTTP "net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher collects published events and fails on request.
+type recordingPublisher struct {
+	events []*domain.ProductEvent
+	failOn string
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event *domain.ProductEvent) error {
+	if event.ID == p.failOn {
+		return errors.New("broker unavailable")
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestOutbox(t *testing.T) {
+	backends := map[string]func(t *testing.T) (port.ProductRepository, port.OutboxRepository){
+		"memory": func(t *testing.T) (port.ProductRepository, port.OutboxRepository) {
+			repo := memory_repository.NewProductRepository()
+			repo.EnableOutbox()
+			return repo, repo.Outbox()
+		},
+		"sqlite": func(t *testing.T) (port.ProductRepository, port.OutboxRepository) {
+			repo, err := sqlite_repository.NewProductRepository(filepath.Join(t.TempDir(), "products.db"))
+			require.NoError(t, err)
+			repo.EnableOutbox()
+			return repo, repo.Outbox()
+		},
+	}
+
+	for name, newRepositories := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := domain.WithActor(context.Background(), "dave")
+			repo, outbox := newRepositories(t)
+
+			product := &domain.Product{ProductName: "Toaster", Price: 40, Stock: 3}
+			require.NoError(t, repo.SaveProduct(ctx, product))
+			product.Stock = 2
+			require.NoError(t, repo.UpdateProduct(ctx, product))
+
+			// A rejected write must not leave an event behind
+			stale := *product
+			stale.Version = 1
+			require.ErrorIs(t, repo.UpdateProduct(ctx, &stale), domain.ErrVersionMismatch)
+
+			require.NoError(t, repo.DeleteProduct(ctx, product.ID, 0))
+			require.NoError(t, repo.RestoreProduct(ctx, product.ID))
+			require.NoError(t, repo.PurgeProduct(ctx, product.ID))
+
+			events, err := outbox.PendingEvents(ctx, 10)
+			require.NoError(t, err)
+
+			var types []domain.EventType
+			for _, event := range events {
+				types = append(types, event.Type)
+				assert.Equal(t, product.ID, event.ProductID)
+				assert.Equal(t, "dave", event.Actor)
+			}
+			assert.Equal(t, []domain.EventType{
+				domain.ProductCreated, domain.ProductUpdated, domain.ProductDeleted, domain.ProductRestored, domain.ProductPurged,
+			}, types)
+			require.NotNil(t, events[1].Product)
+			assert.Equal(t, 2, events[1].Product.Stock)
+			assert.Equal(t, int64(2), events[1].Product.Version)
+			assert.Nil(t, events[2].Product)
+
+			t.Run("relay checkpoints delivered events and retries the rest", func(t *testing.T) {
+				sink := &recordingPublisher{failOn: events[2].ID}
+				relay := application.NewOutboxRelay(outbox, sink, application.WithBatchSize(10))
+
+				published, err := relay.RelayOnce(ctx)
+				assert.Error(t, err)
+				assert.Equal(t, 2, published)
+
+				sink.failOn = ""
+				published, err = relay.RelayOnce(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, 3, published)
+
+				var delivered []domain.EventType
+				for _, event := range sink.events {
+					delivered = append(delivered, event.Type)
+				}
+				assert.Equal(t, types, delivered)
+
+				pending, err := outbox.PendingEvents(ctx, 10)
+				require.NoError(t, err)
+				assert.Empty(t, pending)
+			})
+		})
+	}
+
+	t.Run("records nothing unless enabled", func(t *testing.T) {
+		repo := memory_repository.NewProductRepository()
+		require.NoError(t, repo.SaveProduct(context.Background(), &domain.Product{ProductName: "Fan"}))
+
+		events, err := repo.Outbox().PendingEvents(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("webhook publisher posts events with an idempotency key", func(t *testing.T) {
+		var received domain.ProductEvent
+		var key string
+		status := netHTTP.StatusAccepted
+		server := httptest.NewServer(netHTTP.HandlerFunc(func(w netHTTP.ResponseWriter, r *netHTTP.Request) {
+			key = r.Header.Get("Idempotency-Key")
+			_ = json.NewDecoder(r.Body).Decode(&received)
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		webhook := publisher.NewWebhookPublisher(server.URL, server.Client())
+		event := &domain.ProductEvent{ID: "7", Type: domain.ProductCreated, ProductID: "3"}
+		require.NoError(t, webhook.Publish(context.Background(), event))
+		assert.Equal(t, "7", key)
+		assert.Equal(t, domain.ProductCreated, received.Type)
+
+		status = netHTTP.StatusServiceUnavailable
+		assert.Error(t, webhook.Publish(context.Background(), event))
+	})
+}
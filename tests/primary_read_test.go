@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"context"
+	"goproduct/internals/adapter/http"
+	"goproduct/internals/adapter/repository/cache_repository"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/core/product/domain"
+	"io"
+	netHTTP "net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrimaryReadsMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Use(http.PrimaryReads())
+	app.All("/", func(c *fiber.Ctx) error {
+		return c.SendString(strconv.FormatBool(domain.PrimaryRead(c.UserContext())))
+	})
+
+	tests := []struct {
+		method string
+		header string
+		want   string
+	}{
+		{netHTTP.MethodGet, "", "false"},
+		{netHTTP.MethodGet, "true", "true"},
+		{netHTTP.MethodPost, "", "true"},
+		{netHTTP.MethodPut, "", "true"},
+		{netHTTP.MethodDelete, "", "true"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/", nil)
+		if tt.header != "" {
+			req.Header.Set(http.PrimaryReadHeader, tt.header)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, string(body), "%s with %q", tt.method, tt.header)
+	}
+}
+
+func TestCacheSkipsPrimaryReads(t *testing.T) {
+	ctx := context.Background()
+	memory := memory_repository.NewProductRepository()
+	product := &domain.Product{ProductName: "Item", Price: 1}
+	require.NoError(t, memory.SaveProduct(ctx, product))
+	inner := &countingRepository{ProductRepository: memory}
+	cache := cache_repository.NewProductRepository(inner, 10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.FindProductByID(domain.WithPrimaryRead(ctx), product.ID)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), inner.reads.Load())
+	assert.Equal(t, 0, cache.Stats().Size)
+}
@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"goproduct/internals/adapter/http"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/adapter/repository/sqlite_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"io"
+	netHTTP "net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductAudit(t *testing.T) {
+	backends := map[string]func(t *testing.T) (port.ProductRepository, port.AuditRepository){
+		"memory": func(t *testing.T) (port.ProductRepository, port.AuditRepository) {
+			return memory_repository.NewProductRepository(), memory_repository.NewAuditRepository()
+		},
+		"sqlite": func(t *testing.T) (port.ProductRepository, port.AuditRepository) {
+			repo, err := sqlite_repository.NewProductRepository(filepath.Join(t.TempDir(), "products.db"))
+			require.NoError(t, err)
+			return repo, repo.AuditRepository()
+		},
+	}
+
+	for name, newRepositories := range backends {
+		t.Run(name, func(t *testing.T) {
+			products, audit := newRepositories(t)
+			clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			service := application.NewProductService(products,
+				application.WithAuditRepository(audit),
+				application.WithClock(func() time.Time {
+					clock = clock.Add(time.Minute)
+					return clock
+				}))
+
+			ctx := domain.WithActor(context.Background(), "alice")
+			product := &domain.Product{ProductName: "Kettle", Price: 25, Stock: 4}
+			require.NoError(t, service.CreateProduct(ctx, product))
+
+			product.Price = 22.5
+			require.NoError(t, service.UpdateProduct(domain.WithActor(context.Background(), "bob"), product))
+			require.NoError(t, service.DeleteProduct(context.Background(), product.ID, 0))
+			_, err := service.RestoreProduct(ctx, product.ID)
+			require.NoError(t, err)
+			require.NoError(t, service.PurgeProduct(ctx, product.ID))
+
+			history, err := service.ProductHistory(ctx, product.ID)
+			require.NoError(t, err)
+			require.Len(t, history, 5)
+
+			var actions []domain.AuditAction
+			var actors []string
+			for _, entry := range history {
+				assert.Equal(t, product.ID, entry.ProductID)
+				assert.NotEmpty(t, entry.ID)
+				actions = append(actions, entry.Action)
+				actors = append(actors, entry.Actor)
+			}
+			assert.Equal(t, []domain.AuditAction{
+				domain.AuditCreate, domain.AuditUpdate, domain.AuditDelete, domain.AuditRestore, domain.AuditPurge,
+			}, actions)
+			assert.Equal(t, []string{"alice", "bob", domain.AnonymousActor, "alice", "alice"}, actors)
+
+			assert.True(t, history[0].At.Equal(time.Date(2024, 5, 1, 12, 1, 0, 0, time.UTC)))
+			assert.Len(t, history[0].Changes, 3)
+			assert.Equal(t, map[string]domain.FieldChange{"price": {From: 25.0, To: 22.5}}, history[1].Changes)
+			assert.Empty(t, history[4].Changes)
+		})
+	}
+
+	t.Run("GET /products/:id/history attributes changes to the actor header", func(t *testing.T) {
+		service := application.NewProductService(memory_repository.NewProductRepository(),
+			application.WithAuditRepository(memory_repository.NewAuditRepository()))
+		productHandler := http.NewProductHandlers(service)
+
+		app := fiber.New()
+		app.Use(http.Actor("X-Actor"))
+		app.Post("/products", productHandler.CreateProduct)
+		app.Get("/products/:id/history", productHandler.ProductHistory)
+
+		body, _ := json.Marshal(domain.Product{ProductName: "Mug", Price: 8, Stock: 10})
+		req := httptest.NewRequest(netHTTP.MethodPost, "/products", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "carol")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, netHTTP.StatusCreated, resp.StatusCode)
+
+		resp, err = app.Test(httptest.NewRequest(netHTTP.MethodGet, "/products/1/history", nil))
+		require.NoError(t, err)
+		assert.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var responseBody struct {
+			Data []domain.AuditEntry `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &responseBody))
+		require.Len(t, responseBody.Data, 1)
+		assert.Equal(t, "carol", responseBody.Data[0].Actor)
+		assert.Equal(t, domain.AuditCreate, responseBody.Data[0].Action)
+	})
+	t.Run("keeps a change that could not be audited", func(t *testing.T) {
+		var auditErrs []error
+		service := application.NewProductService(memory_repository.NewProductRepository(),
+			application.WithAuditRepository(failingAuditRepository{}),
+			application.WithAuditErrorHandler(func(err error) {
+				auditErrs = append(auditErrs, err)
+			}))
+
+		product := &domain.Product{ProductName: "Mug", Price: 8}
+		require.NoError(t, service.CreateProduct(context.Background(), product))
+		require.NoError(t, service.UpdateProduct(context.Background(), &domain.Product{ID: product.ID, ProductName: "Cup", Price: 8}))
+
+		found, err := service.GetProductByID(context.Background(), product.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Cup", found.ProductName)
+		require.Len(t, auditErrs, 2)
+		assert.ErrorContains(t, auditErrs[1], "applied but not audited")
+	})
+
+	t.Run("diffs against the version an update replaced", func(t *testing.T) {
+		ctx := context.Background()
+		repo := &racingRepository{ProductRepository: memory_repository.NewProductRepository()}
+		audit := memory_repository.NewAuditRepository()
+		service := application.NewProductService(repo, application.WithAuditRepository(audit))
+		product := &domain.Product{ProductName: "Mug", Price: 8, Stock: 1}
+		require.NoError(t, service.CreateProduct(ctx, product))
+
+		// Another writer changes the stock between the read and the update
+		repo.race = func() {
+			_, err := repo.ProductRepository.AdjustStock(ctx, product.ID, 4)
+			require.NoError(t, err)
+		}
+		require.NoError(t, service.UpdateProduct(ctx, &domain.Product{ID: product.ID, ProductName: "Mug", Price: 8, Stock: 2}))
+
+		history, err := audit.ListAudit(ctx, product.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, map[string]domain.FieldChange{"stock": {From: 5, To: 2}}, history[1].Changes)
+	})
+}
+
+// failingAuditRepository fails to record every entry.
+type failingAuditRepository struct {
+	port.AuditRepository
+}
+
+func (failingAuditRepository) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	return errors.New("audit store down")
+}
+
+// racingRepository runs race once, right before the first update reaches
+// the repository.
+type racingRepository struct {
+	*memory_repository.ProductRepository
+	race func()
+}
+
+func (r *racingRepository) UpdateProduct(ctx context.Context, product *domain.Product) error {
+	if race := r.race; race != nil {
+		r.race = nil
+		race()
+	}
+	return r.ProductRepository.UpdateProduct(ctx, product)
+}
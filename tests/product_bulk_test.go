@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goproduct/internals/adapter/http"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	netHTTP "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkRepository(t *testing.T) {
+	for name, newRepository := range backends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepository(t)
+
+			// More than one multi-row INSERT worth of products
+			products := make([]*domain.Product, 1200)
+			for i := range products {
+				products[i] = &domain.Product{ProductName: fmt.Sprintf("Item %04d", i), Price: float64(i), Stock: i}
+			}
+			require.NoError(t, repo.SaveProducts(ctx, products))
+
+			for _, i := range []int{0, 499, 500, 1199} {
+				found, err := repo.FindProductByID(ctx, products[i].ID)
+				require.NoError(t, err)
+				assert.Equal(t, products[i].ProductName, found.ProductName)
+				assert.Equal(t, int64(1), found.Version)
+			}
+
+			t.Run("rolls back every update on a stale version", func(t *testing.T) {
+				first := &domain.Product{ID: products[0].ID, ProductName: "Renamed", Version: 1}
+				stale := &domain.Product{ID: products[1].ID, ProductName: "Stale", Version: 7}
+
+				err := repo.UpdateProducts(ctx, []*domain.Product{first, stale})
+				var itemErr *domain.BulkItemError
+				require.ErrorAs(t, err, &itemErr)
+				assert.Equal(t, 1, itemErr.Index)
+				assert.ErrorIs(t, err, domain.ErrVersionMismatch)
+
+				found, err := repo.FindProductByID(ctx, products[0].ID)
+				require.NoError(t, err)
+				assert.Equal(t, "Item 0000", found.ProductName)
+			})
+
+			t.Run("applies every update", func(t *testing.T) {
+				first := &domain.Product{ID: products[0].ID, ProductName: "Renamed", Version: 1}
+				second := &domain.Product{ID: products[1].ID, ProductName: "Also renamed", Stock: 3}
+
+				require.NoError(t, repo.UpdateProducts(ctx, []*domain.Product{first, second}))
+				assert.Equal(t, int64(2), first.Version)
+				assert.Equal(t, int64(2), second.Version)
+			})
+
+			t.Run("rolls back every delete on a missing product", func(t *testing.T) {
+				err := repo.DeleteProducts(ctx, []port.BulkDelete{{ID: products[2].ID}, {ID: "99999"}})
+				var itemErr *domain.BulkItemError
+				require.ErrorAs(t, err, &itemErr)
+				assert.Equal(t, 1, itemErr.Index)
+				assert.ErrorIs(t, err, domain.ErrNotFound)
+
+				_, err = repo.FindProductByID(ctx, products[2].ID)
+				assert.NoError(t, err)
+
+				require.NoError(t, repo.DeleteProducts(ctx, []port.BulkDelete{{ID: products[2].ID, Version: 1}, {ID: products[3].ID}}))
+				_, err = repo.FindProductByID(ctx, products[3].ID)
+				assert.ErrorIs(t, err, domain.ErrNotFound)
+			})
+		})
+	}
+}
+
+// partialRepository saves products in order the way MongoDB does without
+// a transaction, stopping at the one named failing when partial writes
+// are allowed.
+type partialRepository struct {
+	*memory_repository.ProductRepository
+	failing string
+}
+
+func (r partialRepository) SaveProducts(ctx context.Context, products []*domain.Product) error {
+	if !domain.PartialWrites(ctx) {
+		return errors.New("transactions need a replica set")
+	}
+	for i, product := range products {
+		if product.ProductName == r.failing {
+			if err := r.ProductRepository.SaveProducts(ctx, products[:i]); err != nil {
+				return err
+			}
+			return &domain.BulkItemError{Index: i, Err: domain.ErrConflict}
+		}
+	}
+	return r.ProductRepository.SaveProducts(ctx, products)
+}
+
+func TestBulkService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates the valid items", func(t *testing.T) {
+		service := application.NewProductService(memory_repository.NewProductRepository())
+		results, err := service.BulkCreateProducts(ctx, []*domain.Product{
+			{ProductName: "Good", Price: 1},
+			{ProductName: "", Price: 1},
+			{ProductName: "Also good", Price: 2},
+		}, false)
+		require.NoError(t, err)
+
+		require.NoError(t, results[0].Err)
+		assert.NotEmpty(t, results[0].Product.ID)
+		assert.ErrorIs(t, results[1].Err, domain.ErrValidation)
+		require.NoError(t, results[2].Err)
+	})
+
+	t.Run("creates nothing when atomic and an item is invalid", func(t *testing.T) {
+		repo := memory_repository.NewProductRepository()
+		service := application.NewProductService(repo)
+		results, err := service.BulkCreateProducts(ctx, []*domain.Product{
+			{ProductName: "Good", Price: 1},
+			{ProductName: "Bad", Price: -1},
+		}, true)
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, results[0].Err, domain.ErrBulkAborted)
+		assert.ErrorIs(t, results[1].Err, domain.ErrValidation)
+		all, err := repo.GetAllProducts(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, all)
+	})
+
+	t.Run("saves the items around a failing one", func(t *testing.T) {
+		repo := partialRepository{ProductRepository: memory_repository.NewProductRepository(), failing: "Failing"}
+		service := application.NewProductService(repo)
+		results, err := service.BulkCreateProducts(ctx, []*domain.Product{
+			{ProductName: "Before", Price: 1},
+			{ProductName: "Failing", Price: 1},
+			{ProductName: "After", Price: 1},
+			{ProductName: "Failing", Price: 1},
+			{ProductName: "Last", Price: 1},
+		}, false)
+		require.NoError(t, err)
+
+		for _, i := range []int{0, 2, 4} {
+			require.NoError(t, results[i].Err)
+			assert.NotEmpty(t, results[i].Product.ID)
+		}
+		assert.ErrorIs(t, results[1].Err, domain.ErrConflict)
+		assert.ErrorIs(t, results[3].Err, domain.ErrConflict)
+		all, err := repo.GetAllProducts(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+	})
+
+	t.Run("updates independently unless atomic", func(t *testing.T) {
+		repo := memory_repository.NewProductRepository()
+		audit := memory_repository.NewAuditRepository()
+		service := application.NewProductService(repo, application.WithAuditRepository(audit))
+		product := &domain.Product{ProductName: "Lamp", Price: 10}
+		require.NoError(t, service.CreateProduct(ctx, product))
+
+		update := func() []*domain.Product {
+			return []*domain.Product{
+				{ID: product.ID, ProductName: "Lamp", Price: 12},
+				{ID: "404", ProductName: "Ghost", Price: 1},
+			}
+		}
+
+		results, err := service.BulkUpdateProducts(ctx, update(), true)
+		require.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, domain.ErrBulkAborted)
+		assert.ErrorIs(t, results[1].Err, domain.ErrNotFound)
+
+		results, err = service.BulkUpdateProducts(ctx, update(), false)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.Equal(t, int64(2), results[0].Product.Version)
+		assert.ErrorIs(t, results[1].Err, domain.ErrNotFound)
+
+		history, err := audit.ListAudit(ctx, product.ID)
+		require.NoError(t, err)
+		assert.Len(t, history, 2)
+	})
+
+	t.Run("rejects oversized requests", func(t *testing.T) {
+		service := application.NewProductService(memory_repository.NewProductRepository())
+		_, err := service.BulkDeleteProducts(ctx, make([]port.BulkDelete, application.MaxBulkItems+1), false)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		_, err = service.BulkDeleteProducts(ctx, nil, false)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
+func TestBulkHandlers(t *testing.T) {
+	repo := memory_repository.NewProductRepository()
+	handlers := http.NewProductHandlers(application.NewProductService(repo))
+
+	app := fiber.New()
+	app.Post("/products/bulk", handlers.BulkCreateProducts)
+	app.Put("/products/bulk", handlers.BulkUpdateProducts)
+	app.Delete("/products/bulk", handlers.BulkDeleteProducts)
+
+	send := func(method string, body any) (int, map[string]any) {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, "/products/bulk", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var decoded map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+		return resp.StatusCode, decoded
+	}
+
+	status, body := send(netHTTP.MethodPost, map[string]any{"items": []map[string]any{
+		{"product_name": "Mug", "price": 8, "stock": 10},
+		{"product_name": "Cup", "price": 6, "stock": 5},
+	}})
+	require.Equal(t, netHTTP.StatusCreated, status)
+	assert.Equal(t, 2.0, body["succeeded"])
+	items := body["data"].([]any)
+	mugID := items[0].(map[string]any)["id"].(string)
+	cupID := items[1].(map[string]any)["id"].(string)
+
+	status, body = send(netHTTP.MethodPut, map[string]any{"atomic": true, "items": []map[string]any{
+		{"id": mugID, "product_name": "Mug", "price": 9, "version": 1},
+		{"id": cupID, "product_name": "Cup", "price": 7, "version": 5},
+	}})
+	require.Equal(t, netHTTP.StatusMultiStatus, status)
+	items = body["data"].([]any)
+	assert.Equal(t, float64(netHTTP.StatusFailedDependency), items[0].(map[string]any)["status"])
+	assert.Equal(t, float64(netHTTP.StatusConflict), items[1].(map[string]any)["status"])
+
+	status, body = send(netHTTP.MethodDelete, map[string]any{"items": []map[string]any{
+		{"id": mugID}, {"id": "404"},
+	}})
+	require.Equal(t, netHTTP.StatusMultiStatus, status)
+	assert.Equal(t, 1.0, body["succeeded"])
+	assert.Equal(t, 1.0, body["failed"])
+
+	status, _ = send(netHTTP.MethodDelete, map[string]any{"items": []map[string]any{}})
+	assert.Equal(t, netHTTP.StatusBadRequest, status)
+
+	// A null item is rejected on its own rather than crashing the server
+	for _, atomic := range []bool{false, true} {
+		status, body = send(netHTTP.MethodPut, map[string]any{"atomic": atomic, "items": []any{
+			nil,
+			map[string]any{"id": cupID, "product_name": "Cup", "price": 7},
+		}})
+		require.Equal(t, netHTTP.StatusMultiStatus, status)
+		items = body["data"].([]any)
+		assert.Equal(t, float64(netHTTP.StatusBadRequest), items[0].(map[string]any)["status"])
+		assert.Equal(t, "product is required", items[0].(map[string]any)["error"])
+	}
+}
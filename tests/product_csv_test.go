@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"goproduct/internals/adapter/http"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"mime/multipart"
+	netHTTP "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportProductsService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("dry run writes nothing", func(t *testing.T) {
+		repo := memory_repository.NewProductRepository()
+		service := application.NewProductService(repo)
+		existing := &domain.Product{ProductName: "Lamp", Price: 10}
+		require.NoError(t, service.CreateProduct(ctx, existing))
+
+		results, err := service.ImportProducts(ctx, []*domain.Product{
+			{ProductName: "New", Price: 1},
+			{ID: existing.ID, ProductName: "Lamp", Price: 12, Version: 1},
+			{ID: existing.ID, ProductName: "Lamp", Price: 12, Version: 4},
+			{ID: "404", ProductName: "Ghost", Price: 1},
+		}, true)
+		require.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.NoError(t, results[1].Err)
+		assert.ErrorIs(t, results[2].Err, domain.ErrVersionMismatch)
+		assert.ErrorIs(t, results[3].Err, domain.ErrNotFound)
+
+		all, err := repo.GetAllProducts(ctx)
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, 10.0, all[0].Price)
+	})
+
+	t.Run("rejects oversized imports", func(t *testing.T) {
+		service := application.NewProductService(memory_repository.NewProductRepository())
+		_, err := service.ImportProducts(ctx, make([]*domain.Product, application.MaxImportRows+1), false)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
+func TestCSVHandlers(t *testing.T) {
+	repo := memory_repository.NewProductRepository()
+	handlers := http.NewProductHandlers(application.NewProductService(repo))
+
+	app := fiber.New()
+	app.Get("/products/export.csv", handlers.ExportProducts)
+	app.Post("/products/import", handlers.ImportProducts)
+
+	upload := func(query, contentType string, body []byte) (int, map[string]any) {
+		req := httptest.NewRequest(netHTTP.MethodPost, "/products/import"+query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var decoded map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+		return resp.StatusCode, decoded
+	}
+	export := func() [][]string {
+		resp, err := app.Test(httptest.NewRequest(netHTTP.MethodGet, "/products/export.csv", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/csv")
+
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		require.NoError(t, err)
+		return records
+	}
+
+	// More rows than one page of the export
+	var rows strings.Builder
+	rows.WriteString("product_name,price,stock,notes\n")
+	for i := 0; i < 150; i++ {
+		fmt.Fprintf(&rows, "\"Item, no. %03d\",%d.5,%d,ignored\n", i, i, i)
+	}
+	status, body := upload("", "text/csv", []byte(rows.String()))
+	require.Equal(t, netHTTP.StatusOK, status, body)
+	assert.Equal(t, 150.0, body["created"])
+
+	records := export()
+	require.Len(t, records, 151)
+	assert.Equal(t, []string{"id", "product_name", "price", "stock", "reserved", "version"}, records[0])
+	assert.Equal(t, "Item, no. 000", records[1][1])
+	assert.Equal(t, "149.5", records[150][2])
+
+	t.Run("reports failing rows by line", func(t *testing.T) {
+		id := records[1][0]
+		csvBody := "id,product_name,price,stock,version\n" +
+			id + ",Renamed,1,1,1\n" +
+			",No price,abc,1,\n" +
+			",,1,1,\n" +
+			id + ",Stale,1,1,9\n" +
+			",Fine,2,2,\n" +
+			"too,few\n"
+
+		var form bytes.Buffer
+		writer := multipart.NewWriter(&form)
+		part, err := writer.CreateFormFile("file", "products.csv")
+		require.NoError(t, err)
+		_, err = part.Write([]byte(csvBody))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		status, body := upload("?dry_run=true", writer.FormDataContentType(), form.Bytes())
+		require.Equal(t, netHTTP.StatusMultiStatus, status, body)
+		assert.Equal(t, true, body["dry_run"])
+		assert.Equal(t, 1.0, body["created"])
+		assert.Equal(t, 1.0, body["updated"])
+		assert.Equal(t, 4.0, body["failed"])
+
+		var lines []float64
+		for _, entry := range body["errors"].([]any) {
+			lines = append(lines, entry.(map[string]any)["line"].(float64))
+		}
+		assert.Equal(t, []float64{3, 4, 5, 7}, lines)
+
+		found, err := repo.FindProductByID(context.Background(), domain.ProductID(id))
+		require.NoError(t, err)
+		assert.Equal(t, "Item, no. 000", found.ProductName)
+
+		status, body = upload("", writer.FormDataContentType(), form.Bytes())
+		require.Equal(t, netHTTP.StatusMultiStatus, status, body)
+		found, err = repo.FindProductByID(context.Background(), domain.ProductID(id))
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", found.ProductName)
+		assert.Len(t, export(), 152)
+	})
+
+	t.Run("rejects a header without required columns", func(t *testing.T) {
+		status, _ := upload("", "text/csv", []byte("product_name,stock\nMug,1\n"))
+		assert.Equal(t, netHTTP.StatusBadRequest, status)
+	})
+
+	t.Run("escapes formulas", func(t *testing.T) {
+		var formulas []*domain.Product
+		for _, name := range []string{"=HYPERLINK(\"http://evil\")", "\tcmd", "'=quoted"} {
+			product := &domain.Product{ProductName: name, Price: 1}
+			require.NoError(t, repo.SaveProduct(context.Background(), product))
+			formulas = append(formulas, product)
+		}
+
+		records := export()
+		cells := records[len(records)-3:]
+		assert.Equal(t, "'=HYPERLINK(\"http://evil\")", cells[0][1])
+		assert.Equal(t, "'\tcmd", cells[1][1])
+		assert.Equal(t, "''=quoted", cells[2][1])
+
+		// Importing the export keeps every name as it was
+		var file bytes.Buffer
+		rows := csv.NewWriter(&file)
+		require.NoError(t, rows.Write([]string{"id", "product_name", "price", "stock"}))
+		for _, cell := range cells {
+			require.NoError(t, rows.Write([]string{cell[0], cell[1], "1", "0"}))
+		}
+		rows.Flush()
+		status, body := upload("", "text/csv", file.Bytes())
+		require.Equal(t, netHTTP.StatusOK, status, body)
+		for _, formula := range formulas {
+			found, err := repo.FindProductByID(context.Background(), formula.ID)
+			require.NoError(t, err)
+			assert.Equal(t, formula.ProductName, found.ProductName)
+		}
+	})
+}
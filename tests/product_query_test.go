@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductQuery(t *testing.T) {
+	for name, newRepository := range backends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepository(t)
+
+			names := map[domain.ProductID]string{}
+			for _, product := range []*domain.Product{
+				{ProductName: "Blue Pen", Price: 10, Stock: 5},
+				{ProductName: "Red pen", Price: 5, Stock: 9},
+				{ProductName: "Notebook", Price: 10, Stock: 1},
+				{ProductName: "Pencil_Case", Price: 20, Stock: 5},
+				{ProductName: "Eraser", Price: 10, Stock: 5},
+			} {
+				require.NoError(t, repo.SaveProduct(ctx, product))
+				names[product.ID] = product.ProductName
+			}
+
+			list := func(query port.ProductQuery) []string {
+				var result []string
+				for {
+					page, err := repo.ListProducts(ctx, query)
+					require.NoError(t, err)
+					for _, product := range page.Products {
+						result = append(result, names[product.ID])
+					}
+					if page.NextCursor == "" {
+						return result
+					}
+					query.Cursor = page.NextCursor
+				}
+			}
+
+			t.Run("sorts across pages with ties broken by ID", func(t *testing.T) {
+				sort, err := port.ParseSort("-price,stock")
+				require.NoError(t, err)
+
+				assert.Equal(t,
+					[]string{"Pencil_Case", "Notebook", "Blue Pen", "Eraser", "Red pen"},
+					list(port.ProductQuery{Sort: sort, Limit: 2}))
+			})
+
+			t.Run("filters by price range and stock", func(t *testing.T) {
+				priceMin, priceMax, stockLT := 6.0, 15.0, 5
+				assert.Equal(t,
+					[]string{"Blue Pen", "Notebook", "Eraser"},
+					list(port.ProductQuery{Filter: port.ProductFilter{PriceMin: &priceMin, PriceMax: &priceMax}, Limit: 2}))
+				assert.Equal(t,
+					[]string{"Notebook"},
+					list(port.ProductQuery{Filter: port.ProductFilter{StockLT: &stockLT}, Limit: 2}))
+			})
+
+			t.Run("matches names case-insensitively and literally", func(t *testing.T) {
+				assert.Equal(t,
+					[]string{"Blue Pen", "Red pen", "Pencil_Case"},
+					list(port.ProductQuery{Filter: port.ProductFilter{NameContains: "pen"}, Limit: 10}))
+				assert.Equal(t,
+					[]string{"Pencil_Case"},
+					list(port.ProductQuery{Filter: port.ProductFilter{NameContains: "_"}, Limit: 10}))
+			})
+
+			t.Run("searches names by relevance", func(t *testing.T) {
+				products, err := repo.SearchProducts(ctx, "blue pen", 10)
+				require.NoError(t, err)
+
+				var found []string
+				for _, product := range products {
+					found = append(found, names[product.ID])
+				}
+				assert.Equal(t, []string{"Blue Pen", "Red pen"}, found)
+
+				products, err = repo.SearchProducts(ctx, "stapler", 10)
+				require.NoError(t, err)
+				assert.Empty(t, products)
+			})
+
+			t.Run("rejects a cursor issued for another sort", func(t *testing.T) {
+				page, err := repo.ListProducts(ctx, port.ProductQuery{Limit: 1})
+				require.NoError(t, err)
+
+				sort, err := port.ParseSort("price")
+				require.NoError(t, err)
+				_, err = repo.ListProducts(ctx, port.ProductQuery{Sort: sort, Limit: 1, Cursor: page.NextCursor})
+				assert.ErrorIs(t, err, domain.ErrValidation)
+			})
+		})
+	}
+}
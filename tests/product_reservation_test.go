@@ -0,0 +1,402 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"goproduct/internals/adapter/http"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/adapter/repository/mysql_repository"
+	"goproduct/internals/adapter/repository/sqlite_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	netHTTP "net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reservationBackends() map[string]func(t *testing.T) (port.ProductRepository, port.ReservationRepository) {
+	return map[string]func(t *testing.T) (port.ProductRepository, port.ReservationRepository){
+		"memory": func(t *testing.T) (port.ProductRepository, port.ReservationRepository) {
+			repo := memory_repository.NewProductRepository()
+			return repo, repo.Reservations()
+		},
+		"sqlite": func(t *testing.T) (port.ProductRepository, port.ReservationRepository) {
+			repo, err := sqlite_repository.NewProductRepository(filepath.Join(t.TempDir(), "products.db"))
+			require.NoError(t, err)
+			return repo, repo.Reservations()
+		},
+	}
+}
+
+func TestProductReservations(t *testing.T) {
+	for name, newRepositories := range reservationBackends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			products, reservations := newRepositories(t)
+			clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			service := application.NewProductService(products,
+				application.WithReservationRepository(reservations),
+				application.WithClock(func() time.Time { return clock }))
+
+			newProduct := func(stock int) *domain.Product {
+				product := &domain.Product{ProductName: "Lamp", Price: 30, Stock: stock}
+				require.NoError(t, service.CreateProduct(ctx, product))
+				return product
+			}
+			stockOf := func(id domain.ProductID) (int, int) {
+				product, err := products.FindProductByID(ctx, id)
+				require.NoError(t, err)
+				return product.Stock, product.Reserved
+			}
+
+			t.Run("moves stock into the held bucket", func(t *testing.T) {
+				product := newProduct(5)
+
+				reservation, err := service.ReserveStock(ctx, product.ID, 2, 0)
+				require.NoError(t, err)
+				assert.NotEmpty(t, reservation.ID)
+				assert.Equal(t, domain.ReservationHeld, reservation.Status)
+				assert.True(t, reservation.ExpiresAt.Equal(clock.Add(application.DefaultReservationTTL)))
+
+				stock, reserved := stockOf(product.ID)
+				assert.Equal(t, 3, stock)
+				assert.Equal(t, 2, reserved)
+
+				found, err := reservations.FindReservation(ctx, reservation.ID)
+				require.NoError(t, err)
+				assert.Equal(t, product.ID, found.ProductID)
+				assert.Equal(t, 2, found.Quantity)
+			})
+
+			t.Run("rejects more than the available stock", func(t *testing.T) {
+				product := newProduct(1)
+
+				_, err := service.ReserveStock(ctx, product.ID, 2, 0)
+				assert.ErrorIs(t, err, domain.ErrInsufficientStock)
+				assert.ErrorIs(t, err, domain.ErrConflict)
+
+				stock, reserved := stockOf(product.ID)
+				assert.Equal(t, 1, stock)
+				assert.Zero(t, reserved)
+			})
+
+			t.Run("commits and releases once", func(t *testing.T) {
+				product := newProduct(5)
+				sold, err := service.ReserveStock(ctx, product.ID, 2, 0)
+				require.NoError(t, err)
+				returned, err := service.ReserveStock(ctx, product.ID, 1, 0)
+				require.NoError(t, err)
+
+				committed, err := service.CommitReservation(ctx, product.ID, sold.ID)
+				require.NoError(t, err)
+				assert.Equal(t, domain.ReservationCommitted, committed.Status)
+				require.NotNil(t, committed.SettledAt)
+
+				released, err := service.ReleaseReservation(ctx, product.ID, returned.ID)
+				require.NoError(t, err)
+				assert.Equal(t, domain.ReservationReleased, released.Status)
+
+				stock, reserved := stockOf(product.ID)
+				assert.Equal(t, 3, stock)
+				assert.Zero(t, reserved)
+
+				_, err = service.ReleaseReservation(ctx, product.ID, sold.ID)
+				assert.ErrorIs(t, err, domain.ErrReservationNotHeld)
+				_, err = service.CommitReservation(ctx, product.ID, returned.ID)
+				assert.ErrorIs(t, err, domain.ErrReservationNotHeld)
+			})
+
+			t.Run("hides reservations of other products", func(t *testing.T) {
+				product := newProduct(5)
+				other := newProduct(5)
+				reservation, err := service.ReserveStock(ctx, product.ID, 1, 0)
+				require.NoError(t, err)
+
+				_, err = service.CommitReservation(ctx, other.ID, reservation.ID)
+				assert.ErrorIs(t, err, domain.ErrReservationNotFound)
+				assert.ErrorIs(t, err, domain.ErrNotFound)
+				_, err = service.CommitReservation(ctx, product.ID, "missing")
+				assert.ErrorIs(t, err, domain.ErrReservationNotFound)
+			})
+
+			t.Run("sweeps expired holds back into stock", func(t *testing.T) {
+				product := newProduct(5)
+				short, err := service.ReserveStock(ctx, product.ID, 2, time.Minute)
+				require.NoError(t, err)
+				long, err := service.ReserveStock(ctx, product.ID, 1, time.Hour)
+				require.NoError(t, err)
+
+				sweeper := application.NewReservationSweeper(reservations,
+					application.WithSweeperClock(func() time.Time { return clock.Add(2 * time.Minute) }))
+				expired, err := sweeper.SweepOnce(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, 1, expired)
+
+				stock, reserved := stockOf(product.ID)
+				assert.Equal(t, 4, stock)
+				assert.Equal(t, 1, reserved)
+
+				found, err := reservations.FindReservation(ctx, short.ID)
+				require.NoError(t, err)
+				assert.Equal(t, domain.ReservationExpired, found.Status)
+				found, err = reservations.FindReservation(ctx, long.ID)
+				require.NoError(t, err)
+				assert.Equal(t, domain.ReservationHeld, found.Status)
+			})
+
+			t.Run("refuses to commit an expired hold", func(t *testing.T) {
+				product := newProduct(5)
+				reservation, err := service.ReserveStock(ctx, product.ID, 2, time.Minute)
+				require.NoError(t, err)
+
+				late := application.NewProductService(products,
+					application.WithReservationRepository(reservations),
+					application.WithClock(func() time.Time { return clock.Add(time.Hour) }))
+				_, err = late.CommitReservation(ctx, product.ID, reservation.ID)
+				assert.ErrorIs(t, err, domain.ErrReservationNotHeld)
+
+				stock, reserved := stockOf(product.ID)
+				assert.Equal(t, 5, stock)
+				assert.Zero(t, reserved)
+			})
+
+			t.Run("never oversells under concurrency", func(t *testing.T) {
+				product := newProduct(10)
+
+				var wg sync.WaitGroup
+				var mu sync.Mutex
+				held := 0
+				for i := 0; i < 25; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := service.ReserveStock(ctx, product.ID, 1, 0); err == nil {
+							mu.Lock()
+							held++
+							mu.Unlock()
+						} else {
+							assert.ErrorIs(t, err, domain.ErrInsufficientStock)
+						}
+					}()
+				}
+				wg.Wait()
+
+				assert.Equal(t, 10, held)
+				stock, reserved := stockOf(product.ID)
+				assert.Zero(t, stock)
+				assert.Equal(t, 10, reserved)
+			})
+
+			t.Run("validates the request", func(t *testing.T) {
+				product := newProduct(5)
+
+				var validationErr *domain.ValidationError
+				_, err := service.ReserveStock(ctx, product.ID, 0, 0)
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, "quantity", validationErr.Field)
+				_, err = service.ReserveStock(ctx, product.ID, 1, 2*application.MaxReservationTTL)
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, "ttl_seconds", validationErr.Field)
+			})
+		})
+	}
+}
+
+func TestReservationHistory(t *testing.T) {
+	type repositories struct {
+		products     port.ProductRepository
+		reservations port.ReservationRepository
+		audit        port.AuditRepository
+		outbox       port.OutboxRepository
+	}
+	backends := map[string]func(t *testing.T) repositories{
+		"memory": func(t *testing.T) repositories {
+			repo := memory_repository.NewProductRepository()
+			repo.EnableOutbox()
+			return repositories{repo, repo.Reservations(), memory_repository.NewAuditRepository(), repo.Outbox()}
+		},
+		"sqlite": func(t *testing.T) repositories {
+			repo, err := sqlite_repository.NewProductRepository(filepath.Join(t.TempDir(), "products.db"))
+			require.NoError(t, err)
+			repo.EnableOutbox()
+			return repositories{repo, repo.Reservations(), repo.AuditRepository(), repo.Outbox()}
+		},
+		// MySQL checks the audit columns are wide enough for every action
+		"mysql": func(t *testing.T) repositories {
+			repo := databaseBackends()["mysql"](t).(*mysql_repository.ProductRepository)
+			repo.EnableOutbox()
+			return repositories{repo, repo.Reservations(), repo.AuditRepository(), drained(t, repo.Outbox())}
+		},
+	}
+
+	for name, newRepositories := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := domain.WithActor(context.Background(), "alice")
+			repos := newRepositories(t)
+			clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			service := application.NewProductService(repos.products,
+				application.WithAuditRepository(repos.audit),
+				application.WithReservationRepository(repos.reservations),
+				application.WithClock(func() time.Time { return clock }))
+
+			product := &domain.Product{ProductName: "Lamp", Price: 30, Stock: 5}
+			require.NoError(t, service.CreateProduct(ctx, product))
+			sold, err := service.ReserveStock(ctx, product.ID, 2, time.Minute)
+			require.NoError(t, err)
+			_, err = service.CommitReservation(ctx, product.ID, sold.ID)
+			require.NoError(t, err)
+			forgotten, err := service.ReserveStock(ctx, product.ID, 1, time.Minute)
+			require.NoError(t, err)
+
+			sweeper := application.NewReservationSweeper(repos.reservations,
+				application.WithSweeperAuditRepository(repos.audit),
+				application.WithSweeperClock(func() time.Time { return clock.Add(time.Hour) }))
+			expired, err := sweeper.SweepOnce(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, 1, expired)
+
+			// Every stock movement is published with the product's new state
+			events, err := repos.outbox.PendingEvents(ctx, 10)
+			require.NoError(t, err)
+			require.Len(t, events, 5)
+			var states [][2]int
+			for _, event := range events[1:] {
+				assert.Equal(t, domain.ProductUpdated, event.Type)
+				states = append(states, [2]int{event.Product.Stock, event.Product.Reserved})
+			}
+			assert.Equal(t, [][2]int{{3, 2}, {3, 0}, {2, 1}, {3, 0}}, states)
+			assert.Equal(t, int64(5), events[4].Product.Version)
+			assert.Equal(t, application.SweeperActor, events[4].Actor)
+
+			history, err := service.ProductHistory(ctx, product.ID)
+			require.NoError(t, err)
+			require.Len(t, history, 5)
+			var actions []domain.AuditAction
+			for _, entry := range history {
+				actions = append(actions, entry.Action)
+			}
+			assert.Equal(t, []domain.AuditAction{
+				domain.AuditCreate, domain.AuditReserve, domain.AuditSettleReservation,
+				domain.AuditReserve, domain.AuditSettleReservation,
+			}, actions)
+			assert.Equal(t, sold.ID, history[1].Reason)
+			assert.Equal(t, "committed", history[2].Changes["status"].To)
+			assert.Equal(t, forgotten.ID, history[4].Reason)
+			assert.Equal(t, "expired", history[4].Changes["status"].To)
+			assert.Equal(t, application.SweeperActor, history[4].Actor)
+		})
+	}
+}
+
+func TestMemoryReservationPruning(t *testing.T) {
+	ctx := context.Background()
+	repo := memory_repository.NewProductRepository()
+	reservations := repo.Reservations()
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	service := application.NewProductService(repo,
+		application.WithReservationRepository(reservations),
+		application.WithClock(func() time.Time { return clock }))
+
+	product := &domain.Product{ProductName: "Lamp", Price: 30, Stock: 5}
+	require.NoError(t, service.CreateProduct(ctx, product))
+	settled, err := service.ReserveStock(ctx, product.ID, 1, time.Minute)
+	require.NoError(t, err)
+	_, err = service.CommitReservation(ctx, product.ID, settled.ID)
+	require.NoError(t, err)
+	late, err := service.ReserveStock(ctx, product.ID, 1, 24*time.Hour)
+	require.NoError(t, err)
+
+	sweepAt := func(at time.Time) {
+		sweeper := application.NewReservationSweeper(reservations,
+			application.WithSweeperClock(func() time.Time { return at }))
+		_, err := sweeper.SweepOnce(ctx)
+		require.NoError(t, err)
+	}
+
+	// A settled reservation can still be looked up for a while
+	sweepAt(clock.Add(time.Hour))
+	_, err = reservations.FindReservation(ctx, settled.ID)
+	require.NoError(t, err)
+
+	sweepAt(clock.Add(memory_repository.SettledRetention + time.Hour))
+	_, err = reservations.FindReservation(ctx, settled.ID)
+	assert.ErrorIs(t, err, domain.ErrReservationNotFound)
+	// The hold that sweep expired has only just been settled
+	found, err := reservations.FindReservation(ctx, late.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ReservationExpired, found.Status)
+}
+
+// drained marks every event already in a shared outbox published, so a
+// test sees only the events it caused.
+func drained(t *testing.T, outbox port.OutboxRepository) port.OutboxRepository {
+	ctx := context.Background()
+	for {
+		events, err := outbox.PendingEvents(ctx, 100)
+		require.NoError(t, err)
+		if len(events) == 0 {
+			return outbox
+		}
+		ids := make([]string, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		require.NoError(t, outbox.MarkPublished(ctx, ids))
+	}
+}
+
+func TestReservationHandlers(t *testing.T) {
+	repo := memory_repository.NewProductRepository()
+	service := application.NewProductService(repo, application.WithReservationRepository(repo.Reservations()))
+	handlers := http.NewProductHandlers(service)
+
+	app := fiber.New()
+	app.Post("/products/:id/reservations", handlers.CreateReservation)
+	app.Post("/products/:id/reservations/:reservation_id/commit", handlers.CommitReservation)
+	app.Post("/products/:id/reservations/:reservation_id/release", handlers.ReleaseReservation)
+
+	product := &domain.Product{ProductName: "Desk", Price: 120, Stock: 3}
+	require.NoError(t, service.CreateProduct(context.Background(), product))
+
+	post := func(path string, body any) (int, map[string]any) {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(netHTTP.MethodPost, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var decoded map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+		return resp.StatusCode, decoded
+	}
+
+	status, body := post("/products/"+product.ID.String()+"/reservations", map[string]any{"quantity": 2, "ttl_seconds": 60})
+	require.Equal(t, netHTTP.StatusCreated, status)
+	reservation := body["data"].(map[string]any)
+	assert.Equal(t, "held", reservation["status"])
+	reservationID := reservation["id"].(string)
+
+	status, _ = post("/products/"+product.ID.String()+"/reservations", map[string]any{"quantity": 2})
+	assert.Equal(t, netHTTP.StatusConflict, status)
+
+	status, body = post("/products/"+product.ID.String()+"/reservations/"+reservationID+"/commit", nil)
+	require.Equal(t, netHTTP.StatusOK, status)
+	assert.Equal(t, "committed", body["data"].(map[string]any)["status"])
+
+	status, _ = post("/products/"+product.ID.String()+"/reservations/"+reservationID+"/release", nil)
+	assert.Equal(t, netHTTP.StatusConflict, status)
+
+	status, _ = post("/products/"+product.ID.String()+"/reservations/unknown/release", nil)
+	assert.Equal(t, netHTTP.StatusNotFound, status)
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"goproduct/internals/adapter/http"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	netHTTP "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdjustStock(t *testing.T) {
+	for name, newRepository := range backends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepository(t)
+
+			product := &domain.Product{ProductName: "Chair", Price: 40, Stock: 5}
+			require.NoError(t, repo.SaveProduct(ctx, product))
+
+			t.Run("adds and removes stock", func(t *testing.T) {
+				adjusted, err := repo.AdjustStock(ctx, product.ID, 3)
+				require.NoError(t, err)
+				assert.Equal(t, 8, adjusted.Stock)
+				assert.Equal(t, int64(2), adjusted.Version)
+
+				adjusted, err = repo.AdjustStock(ctx, product.ID, -8)
+				require.NoError(t, err)
+				assert.Zero(t, adjusted.Stock)
+			})
+
+			t.Run("never goes below zero", func(t *testing.T) {
+				_, err := repo.AdjustStock(ctx, product.ID, -1)
+				assert.ErrorIs(t, err, domain.ErrInsufficientStock)
+
+				current, err := repo.FindProductByID(ctx, product.ID)
+				require.NoError(t, err)
+				assert.Zero(t, current.Stock)
+			})
+
+			t.Run("reports missing products", func(t *testing.T) {
+				_, err := repo.AdjustStock(ctx, "999", 1)
+				assert.ErrorIs(t, err, domain.ErrNotFound)
+
+				require.NoError(t, repo.DeleteProduct(ctx, product.ID, 0))
+				_, err = repo.AdjustStock(ctx, product.ID, 1)
+				assert.ErrorIs(t, err, domain.ErrNotFound)
+			})
+
+			t.Run("loses no concurrent adjustment", func(t *testing.T) {
+				product := &domain.Product{ProductName: "Stool", Price: 15, Stock: 10}
+				require.NoError(t, repo.SaveProduct(ctx, product))
+
+				var wg sync.WaitGroup
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func(delta int) {
+						defer wg.Done()
+						_, err := repo.AdjustStock(ctx, product.ID, delta)
+						assert.NoError(t, err)
+					}(1 - 2*(i%2))
+				}
+				wg.Wait()
+
+				current, err := repo.FindProductByID(ctx, product.ID)
+				require.NoError(t, err)
+				assert.Equal(t, 10, current.Stock)
+				assert.Equal(t, int64(21), current.Version)
+			})
+		})
+	}
+}
+
+func TestAdjustStockHandler(t *testing.T) {
+	repo := memory_repository.NewProductRepository()
+	audit := memory_repository.NewAuditRepository()
+	service := application.NewProductService(repo, application.WithAuditRepository(audit))
+	handlers := http.NewProductHandlers(service)
+
+	app := fiber.New()
+	app.Post("/products/:id/stock/adjust", handlers.AdjustStock)
+
+	product := &domain.Product{ProductName: "Shelf", Price: 60, Stock: 2}
+	require.NoError(t, service.CreateProduct(context.Background(), product))
+
+	adjust := func(body map[string]any) (*netHTTP.Response, map[string]any) {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(netHTTP.MethodPost, "/products/"+product.ID.String()+"/stock/adjust", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var decoded map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+		return resp, decoded
+	}
+
+	resp, body := adjust(map[string]any{"delta": -2, "reason": "sale"})
+	require.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get(fiber.HeaderETag))
+	assert.Equal(t, 0.0, body["data"].(map[string]any)["stock"])
+
+	resp, _ = adjust(map[string]any{"delta": -1, "reason": "sale"})
+	assert.Equal(t, netHTTP.StatusConflict, resp.StatusCode)
+
+	resp, _ = adjust(map[string]any{"delta": 1, "reason": "gift"})
+	assert.Equal(t, netHTTP.StatusBadRequest, resp.StatusCode)
+
+	history, err := audit.ListAudit(context.Background(), product.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, domain.AuditAdjustStock, history[1].Action)
+	assert.Equal(t, "sale", history[1].Reason)
+	assert.Equal(t, map[string]domain.FieldChange{"stock": {From: 2, To: 0}}, history[1].Changes)
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goproduct/internals/adapter/http"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	netHTTP "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamProductsRepository(t *testing.T) {
+	for name, newRepository := range backends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepository(t)
+
+			var ids []domain.ProductID
+			for i := 0; i < 5; i++ {
+				product := &domain.Product{ProductName: fmt.Sprintf("Item %d", i), Price: 1}
+				require.NoError(t, repo.SaveProduct(ctx, product))
+				ids = append(ids, product.ID)
+			}
+			require.NoError(t, repo.DeleteProduct(ctx, ids[2], 0))
+
+			var streamed []domain.ProductID
+			err := repo.StreamProducts(ctx, func(product *domain.Product) error {
+				streamed = append(streamed, product.ID)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []domain.ProductID{ids[0], ids[1], ids[3], ids[4]}, streamed)
+
+			stop := errors.New("stop")
+			calls := 0
+			err = repo.StreamProducts(ctx, func(*domain.Product) error {
+				calls++
+				return stop
+			})
+			assert.ErrorIs(t, err, stop)
+			assert.Equal(t, 1, calls)
+
+			// A slow consumer must not hold up other queries, across
+			// several pages of products
+			batch := make([]*domain.Product, 1200)
+			for i := range batch {
+				batch[i] = &domain.Product{ProductName: fmt.Sprintf("Bulk %d", i), Price: 1}
+			}
+			require.NoError(t, repo.SaveProducts(ctx, batch))
+			queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			count := 0
+			err = repo.StreamProducts(queryCtx, func(product *domain.Product) error {
+				count++
+				_, err := repo.FindProductByID(queryCtx, product.ID)
+				return err
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 1204, count)
+		})
+	}
+}
+
+func TestStreamProductsHandler(t *testing.T) {
+	stream := func(t *testing.T, repo *MockProductRepository) []map[string]any {
+		app := fiber.New()
+		app.Get("/products/stream", http.NewProductHandlers(application.NewProductService(repo)).StreamProducts)
+
+		resp, err := app.Test(httptest.NewRequest(netHTTP.MethodGet, "/products/stream", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+		var lines []map[string]any
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var line map[string]any
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+		require.NoError(t, scanner.Err())
+		return lines
+	}
+
+	t.Run("writes one product per line", func(t *testing.T) {
+		products := make([]*domain.Product, 250)
+		for i := range products {
+			products[i] = &domain.Product{ID: domain.ProductID(fmt.Sprint(i + 1)), ProductName: "Item", Price: 1}
+		}
+		repo := new(MockProductRepository)
+		repo.On("StreamProducts").Return(products, nil)
+
+		lines := stream(t, repo)
+		require.Len(t, lines, 250)
+		assert.Equal(t, "1", lines[0]["id"])
+		assert.Equal(t, "250", lines[249]["id"])
+	})
+
+	t.Run("ends with an error line when the backend fails", func(t *testing.T) {
+		repo := new(MockProductRepository)
+		repo.On("StreamProducts").Return([]*domain.Product{{ID: "1", ProductName: "Item"}}, errors.New("connection reset"))
+
+		lines := stream(t, repo)
+		require.Len(t, lines, 2)
+		assert.Equal(t, "1", lines[0]["id"])
+		assert.Equal(t, "stream interrupted", lines[1]["error"])
+	})
+
+	t.Run("streams the memory repository", func(t *testing.T) {
+		repo := memory_repository.NewProductRepository()
+		require.NoError(t, repo.SaveProduct(context.Background(), &domain.Product{ProductName: "Mug", Price: 8}))
+
+		app := fiber.New()
+		app.Get("/products/stream", http.NewProductHandlers(application.NewProductService(repo)).StreamProducts)
+		resp, err := app.Test(httptest.NewRequest(netHTTP.MethodGet, "/products/stream", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var product domain.Product
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+		assert.Equal(t, "Mug", product.ProductName)
+	})
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductTransfer(t *testing.T) {
+	ctx := context.Background()
+	all := backends()
+
+	for from, newSource := range all {
+		for to, newTarget := range all {
+			if from == to {
+				continue
+			}
+			t.Run(from+" to "+to, func(t *testing.T) {
+				source := newSource(t)
+				for i := 0; i < 25; i++ {
+					product := &domain.Product{ProductName: fmt.Sprintf("Item %02d", i), Price: float64(i) + 0.25, Stock: i}
+					require.NoError(t, source.SaveProduct(ctx, product))
+					if i == 7 {
+						require.NoError(t, source.DeleteProduct(ctx, product.ID, 0))
+					}
+				}
+				target := newTarget(t)
+
+				// The first run dies after two batches
+				crash := errors.New("crash")
+				var saved application.TransferCheckpoint
+				var mappings []application.IDMapping
+				recordIDs := application.WithIDMappings(func(batch []application.IDMapping) error {
+					mappings = append(mappings, batch...)
+					return nil
+				})
+				_, err := application.NewProductTransfer(source, target,
+					application.WithTransferBatchSize(10),
+					recordIDs,
+					application.WithCheckpointSaver(func(checkpoint application.TransferCheckpoint) error {
+						if checkpoint.Copied > 10 {
+							return crash
+						}
+						saved = checkpoint
+						return nil
+					}),
+				).Run(ctx, application.TransferCheckpoint{})
+				require.ErrorIs(t, err, crash)
+				require.Equal(t, 10, saved.Copied)
+
+				// Resuming from the saved checkpoint copies the second batch
+				// again, which the report catches
+				transfer := application.NewProductTransfer(source, target, application.WithTransferBatchSize(10), recordIDs)
+				done, err := transfer.Run(ctx, saved)
+				require.NoError(t, err)
+				assert.True(t, done.Done)
+				assert.Equal(t, 24, done.Copied)
+
+				report, err := transfer.Verify(ctx)
+				require.NoError(t, err)
+				assert.False(t, report.Match)
+				assert.Equal(t, 24, report.Source.Count)
+				assert.Equal(t, 34, report.Target.Count)
+				assert.Len(t, mappings, 34)
+
+				// A clean copy matches
+				clean := newTarget(t)
+				transfer = application.NewProductTransfer(source, clean, application.WithTransferBatchSize(10))
+				_, err = transfer.Run(ctx, application.TransferCheckpoint{})
+				require.NoError(t, err)
+				report, err = transfer.Verify(ctx)
+				require.NoError(t, err)
+				assert.True(t, report.Match)
+				assert.Equal(t, report.Source, report.Target)
+			})
+		}
+	}
+}
+
+func TestProductTransferFailedBatch(t *testing.T) {
+	ctx := context.Background()
+	source := memory_repository.NewProductRepository()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, source.SaveProduct(ctx, &domain.Product{ProductName: fmt.Sprintf("Item %02d", i), Price: 1}))
+	}
+	target := partialRepository{ProductRepository: memory_repository.NewProductRepository(), failing: "Item 03"}
+
+	checkpoint, err := application.NewProductTransfer(source, target).Run(ctx, application.TransferCheckpoint{})
+	require.Error(t, err)
+	assert.Zero(t, checkpoint.Copied)
+
+	// Nothing of the failed batch is left behind for a resume to duplicate
+	all, err := target.GetAllProducts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestProductTransferChecksum(t *testing.T) {
+	ctx := context.Background()
+	source := memory_repository.NewProductRepository()
+	target := memory_repository.NewProductRepository()
+	require.NoError(t, source.SaveProduct(ctx, &domain.Product{ProductName: "Lamp", Price: 10, Stock: 3}))
+	require.NoError(t, target.SaveProduct(ctx, &domain.Product{ProductName: "Lamp", Price: 10, Stock: 4}))
+
+	report, err := application.NewProductTransfer(source, target).Verify(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, report.Source.Count, report.Target.Count)
+	assert.NotEqual(t, report.Source.Checksum, report.Target.Checksum)
+	assert.False(t, report.Match)
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductTrash(t *testing.T) {
+	for name, newRepository := range backends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepository(t)
+
+			kept := &domain.Product{ProductName: "Desk Lamp", Price: 30, Stock: 2}
+			trashed := &domain.Product{ProductName: "Floor Lamp", Price: 80, Stock: 1}
+			require.NoError(t, repo.SaveProduct(ctx, kept))
+			require.NoError(t, repo.SaveProduct(ctx, trashed))
+			require.NoError(t, repo.DeleteProduct(ctx, trashed.ID, 0))
+
+			ids := func(products []*domain.Product) []domain.ProductID {
+				result := make([]domain.ProductID, len(products))
+				for i, product := range products {
+					result[i] = product.ID
+				}
+				return result
+			}
+
+			t.Run("hides deleted products from reads", func(t *testing.T) {
+				_, err := repo.FindProductByID(ctx, trashed.ID)
+				assert.ErrorIs(t, err, domain.ErrNotFound)
+
+				all, err := repo.GetAllProducts(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, []domain.ProductID{kept.ID}, ids(all))
+
+				page, err := repo.ListProducts(ctx, port.ProductQuery{Limit: 10})
+				require.NoError(t, err)
+				assert.Equal(t, []domain.ProductID{kept.ID}, ids(page.Products))
+
+				found, err := repo.SearchProducts(ctx, "lamp", 10)
+				require.NoError(t, err)
+				assert.Equal(t, []domain.ProductID{kept.ID}, ids(found))
+
+				assert.ErrorIs(t, repo.UpdateProduct(ctx, trashed), domain.ErrNotFound)
+				assert.ErrorIs(t, repo.DeleteProduct(ctx, trashed.ID, 0), domain.ErrNotFound)
+			})
+
+			t.Run("lists the trash", func(t *testing.T) {
+				page, err := repo.ListProducts(ctx, port.ProductQuery{
+					Filter: port.ProductFilter{Deleted: true},
+					Limit:  10,
+				})
+				require.NoError(t, err)
+				require.Equal(t, []domain.ProductID{trashed.ID}, ids(page.Products))
+				assert.NotNil(t, page.Products[0].DeletedAt)
+				assert.Equal(t, int64(2), page.Products[0].Version)
+			})
+
+			t.Run("restores only products in the trash", func(t *testing.T) {
+				assert.ErrorIs(t, repo.RestoreProduct(ctx, kept.ID), domain.ErrNotFound)
+				require.NoError(t, repo.RestoreProduct(ctx, trashed.ID))
+
+				found, err := repo.FindProductByID(ctx, trashed.ID)
+				require.NoError(t, err)
+				assert.Nil(t, found.DeletedAt)
+				assert.Equal(t, int64(3), found.Version)
+			})
+
+			t.Run("purges live and deleted products", func(t *testing.T) {
+				require.NoError(t, repo.DeleteProduct(ctx, trashed.ID, 0))
+				require.NoError(t, repo.PurgeProduct(ctx, trashed.ID))
+				require.NoError(t, repo.PurgeProduct(ctx, kept.ID))
+
+				assert.ErrorIs(t, repo.PurgeProduct(ctx, kept.ID), domain.ErrNotFound)
+				assert.ErrorIs(t, repo.RestoreProduct(ctx, trashed.ID), domain.ErrNotFound)
+
+				page, err := repo.ListProducts(ctx, port.ProductQuery{
+					Filter: port.ProductFilter{Deleted: true},
+					Limit:  10,
+				})
+				require.NoError(t, err)
+				assert.Empty(t, page.Products)
+			})
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"goproduct/internals/core/product/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductVersion(t *testing.T) {
+	for name, newRepository := range backends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepository(t)
+
+			product := &domain.Product{ProductName: "Lamp", Price: 30, Stock: 2}
+			require.NoError(t, repo.SaveProduct(ctx, product))
+			assert.Equal(t, int64(1), product.Version)
+
+			t.Run("bumps the version on every update", func(t *testing.T) {
+				product.Stock = 3
+				require.NoError(t, repo.UpdateProduct(ctx, product))
+				assert.Equal(t, int64(2), product.Version)
+
+				found, err := repo.FindProductByID(ctx, product.ID)
+				require.NoError(t, err)
+				assert.Equal(t, int64(2), found.Version)
+				assert.Equal(t, 3, found.Stock)
+			})
+
+			t.Run("rejects writes against a stale version", func(t *testing.T) {
+				stale := *product
+				stale.Version = 1
+				assert.ErrorIs(t, repo.UpdateProduct(ctx, &stale), domain.ErrVersionMismatch)
+				assert.ErrorIs(t, repo.UpdateProduct(ctx, &stale), domain.ErrConflict)
+				assert.ErrorIs(t, repo.DeleteProduct(ctx, product.ID, 1), domain.ErrVersionMismatch)
+			})
+
+			t.Run("treats version 0 as unconditional", func(t *testing.T) {
+				blind := *product
+				blind.Version = 0
+				require.NoError(t, repo.UpdateProduct(ctx, &blind))
+				assert.Equal(t, int64(3), blind.Version)
+			})
+
+			t.Run("deletes the current version", func(t *testing.T) {
+				require.NoError(t, repo.DeleteProduct(ctx, product.ID, 3))
+				assert.ErrorIs(t, repo.DeleteProduct(ctx, product.ID, 3), domain.ErrNotFound)
+				assert.ErrorIs(t, repo.UpdateProduct(ctx, product), domain.ErrNotFound)
+			})
+		})
+	}
+}
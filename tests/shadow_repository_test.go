@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"goproduct/internals/adapter/repository/memory_repository"
+	"goproduct/internals/adapter/repository/shadow_repository"
+	"goproduct/internals/core/product/application"
+	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRepository fails every write.
+type failingRepository struct {
+	port.ProductRepository
+}
+
+func (failingRepository) SaveProduct(ctx context.Context, product *domain.Product) error {
+	return errors.New("shadow down")
+}
+
+// hangingRepository blocks every write until its context is done.
+type hangingRepository struct {
+	port.ProductRepository
+}
+
+func (hangingRepository) SaveProduct(ctx context.Context, product *domain.Product) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestShadowRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("mirrors writes", func(t *testing.T) {
+		primary := memory_repository.NewProductRepository()
+		shadow := backends()["sqlite"](t)
+		repo := shadow_repository.NewProductRepository(primary, shadow)
+		service := application.NewProductService(repo,
+			application.WithReservationRepository(repo.Reservations(primary.Reservations())))
+
+		created, err := service.BulkCreateProducts(ctx, []*domain.Product{
+			{ProductName: "Lamp", Price: 10, Stock: 5},
+			{ProductName: "Mug", Price: 4, Stock: 9},
+		}, true)
+		require.NoError(t, err)
+		lamp, mug := created[0].Product, created[1].Product
+
+		require.NoError(t, service.UpdateProduct(ctx, &domain.Product{ID: lamp.ID, ProductName: "Desk lamp", Price: 12, Stock: 5}))
+		_, err = service.AdjustStock(ctx, lamp.ID, 3, domain.StockRestock)
+		require.NoError(t, err)
+		_, err = service.ReserveStock(ctx, lamp.ID, 2, time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, service.DeleteProduct(ctx, mug.ID, 0))
+
+		mirrored, err := shadow.GetAllProducts(ctx)
+		require.NoError(t, err)
+		require.Len(t, mirrored, 1)
+		assert.Equal(t, "Desk lamp", mirrored[0].ProductName)
+		assert.Equal(t, 12.0, mirrored[0].Price)
+		assert.Equal(t, 6, mirrored[0].Stock)
+
+		stats := repo.Stats()
+		assert.Equal(t, uint64(6), stats.Writes)
+		assert.Zero(t, stats.WriteErrors)
+	})
+
+	t.Run("skips products without a shadow copy", func(t *testing.T) {
+		primary := memory_repository.NewProductRepository()
+		old := &domain.Product{ProductName: "Old", Price: 1}
+		require.NoError(t, primary.SaveProduct(ctx, old))
+		repo := shadow_repository.NewProductRepository(primary, memory_repository.NewProductRepository())
+
+		require.NoError(t, repo.UpdateProduct(ctx, &domain.Product{ID: old.ID, ProductName: "Older", Price: 1}))
+		assert.Equal(t, uint64(1), repo.Stats().Unmapped)
+		assert.Zero(t, repo.Stats().Writes)
+	})
+
+	t.Run("never fails a request for the shadow", func(t *testing.T) {
+		var divergences []shadow_repository.Divergence
+		repo := shadow_repository.NewProductRepository(memory_repository.NewProductRepository(), failingRepository{},
+			shadow_repository.WithDivergenceHandler(func(divergence shadow_repository.Divergence) {
+				divergences = append(divergences, divergence)
+			}))
+
+		require.NoError(t, repo.SaveProduct(ctx, &domain.Product{ProductName: "Lamp", Price: 1}))
+		require.Len(t, divergences, 1)
+		assert.EqualError(t, divergences[0].Err, "shadow down")
+		assert.Equal(t, uint64(1), repo.Stats().WriteErrors)
+	})
+
+	t.Run("does not wait on a hung shadow", func(t *testing.T) {
+		var divergences []shadow_repository.Divergence
+		repo := shadow_repository.NewProductRepository(memory_repository.NewProductRepository(), hangingRepository{},
+			shadow_repository.WithShadowTimeout(20*time.Millisecond),
+			shadow_repository.WithDivergenceHandler(func(divergence shadow_repository.Divergence) {
+				divergences = append(divergences, divergence)
+			}))
+
+		done := make(chan error, 1)
+		go func() {
+			done <- repo.SaveProduct(ctx, &domain.Product{ProductName: "Lamp", Price: 1})
+		}()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("the primary write waited on the shadow")
+		}
+		require.Len(t, divergences, 1)
+		assert.ErrorIs(t, divergences[0].Err, context.DeadlineExceeded)
+	})
+
+	t.Run("mirrors the products a partial bulk write saved", func(t *testing.T) {
+		primary := partialRepository{ProductRepository: memory_repository.NewProductRepository(), failing: "Failing"}
+		shadow := memory_repository.NewProductRepository()
+		repo := shadow_repository.NewProductRepository(primary, shadow)
+
+		err := repo.SaveProducts(domain.WithPartialWrites(ctx), []*domain.Product{
+			{ProductName: "Saved", Price: 1},
+			{ProductName: "Failing", Price: 1},
+		})
+		assert.ErrorIs(t, err, domain.ErrConflict)
+		mirrored, err := shadow.GetAllProducts(ctx)
+		require.NoError(t, err)
+		require.Len(t, mirrored, 1)
+		assert.Equal(t, "Saved", mirrored[0].ProductName)
+		assert.Equal(t, uint64(1), repo.Stats().Writes)
+	})
+
+	t.Run("reports copies that differ", func(t *testing.T) {
+		primary := memory_repository.NewProductRepository()
+		shadow := memory_repository.NewProductRepository()
+		found := make(chan shadow_repository.Divergence, 1)
+		repo := shadow_repository.NewProductRepository(primary, shadow,
+			shadow_repository.WithDivergenceHandler(func(divergence shadow_repository.Divergence) {
+				found <- divergence
+			}))
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go repo.Run(runCtx)
+
+		product := &domain.Product{ProductName: "Lamp", Price: 10, Stock: 1}
+		require.NoError(t, repo.SaveProduct(ctx, product))
+		mirrored, err := shadow.GetAllProducts(ctx)
+		require.NoError(t, err)
+		_, err = shadow.AdjustStock(ctx, mirrored[0].ID, 4)
+		require.NoError(t, err)
+
+		_, err = repo.FindProductByID(ctx, product.ID)
+		require.NoError(t, err)
+		select {
+		case divergence := <-found:
+			assert.Equal(t, product.ID, divergence.ProductID)
+			assert.Equal(t, 1, divergence.Primary.Stock)
+			assert.Equal(t, 5, divergence.Shadow.Stock)
+		case <-time.After(time.Second):
+			t.Fatal("no divergence reported")
+		}
+		assert.Equal(t, uint64(1), repo.Stats().Mismatches)
+	})
+}
+
+func TestShadowIDMapFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.csv")
+	require.NoError(t, os.WriteFile(path, []byte("source_id,target_id\n1,aa\n"), 0o644))
+
+	ids, err := shadow_repository.OpenIDMap(path)
+	require.NoError(t, err)
+	require.NoError(t, ids.Put("2", "bb"))
+	require.NoError(t, ids.Close())
+
+	ids, err = shadow_repository.OpenIDMap(path)
+	require.NoError(t, err)
+	defer ids.Close()
+	assert.Equal(t, 2, ids.Len())
+	shadowID, ok := ids.Lookup("1")
+	assert.True(t, ok)
+	assert.Equal(t, domain.ProductID("aa"), shadowID)
+	shadowID, _ = ids.Lookup("2")
+	assert.Equal(t, domain.ProductID("bb"), shadowID)
+}
@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"context"
+	"goproduct/internals/adapter/repository/sqlite_repository"
+	"goproduct/internals/core/product/domain"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "products.db")
+
+	repo, err := sqlite_repository.NewProductRepository(path)
+	assert.NoError(t, err)
+
+	product := &domain.Product{ProductName: "Monitor", Price: 199.0, Stock: 4}
+	assert.NoError(t, repo.SaveProduct(ctx, product))
+	assert.Equal(t, domain.ProductID("1"), product.ID)
+
+	// Reopening the file must keep the schema and data intact
+	repo, err = sqlite_repository.NewProductRepository(path)
+	assert.NoError(t, err)
+
+	found, err := repo.FindProductByID(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Monitor", found.ProductName)
+	assert.Equal(t, 4, found.Stock)
+
+	products, err := repo.GetAllProducts(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+}
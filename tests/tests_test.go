@@ -2,11 +2,12 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"goproduct/internals/adapter/http"
 	"goproduct/internals/core/product/application"
 	"goproduct/internals/core/product/domain"
+	"goproduct/internals/core/product/port"
 	"io"
 	netHTTP "net/http"
 	"net/http/httptest"
@@ -23,13 +24,13 @@ type MockProductRepository struct {
 }
 
 // SaveProduct mocks the SaveProduct method
-func (m *MockProductRepository) SaveProduct(product *domain.Product) error {
+func (m *MockProductRepository) SaveProduct(ctx context.Context, product *domain.Product) error {
 	args := m.Called(product)
 	return args.Error(0)
 }
 
 // FindProductByID mocks the FindProductByID method
-func (m *MockProductRepository) FindProductByID(productID interface{}) (*domain.Product, error) {
+func (m *MockProductRepository) FindProductByID(ctx context.Context, productID domain.ProductID) (*domain.Product, error) {
 	args := m.Called(productID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -38,19 +39,84 @@ func (m *MockProductRepository) FindProductByID(productID interface{}) (*domain.
 }
 
 // GetAllProducts mocks the GetAllProducts method
-func (m *MockProductRepository) GetAllProducts() ([]*domain.Product, error) {
+func (m *MockProductRepository) GetAllProducts(ctx context.Context) ([]*domain.Product, error) {
 	args := m.Called()
 	return args.Get(0).([]*domain.Product), args.Error(1)
 }
 
+// StreamProducts mocks the StreamProducts method
+func (m *MockProductRepository) StreamProducts(ctx context.Context, fn func(*domain.Product) error) error {
+	args := m.Called()
+	for _, product := range args.Get(0).([]*domain.Product) {
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+// ListProducts mocks the ListProducts method
+func (m *MockProductRepository) ListProducts(ctx context.Context, query port.ProductQuery) (*port.Page, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*port.Page), args.Error(1)
+}
+
+// SearchProducts mocks the SearchProducts method
+func (m *MockProductRepository) SearchProducts(ctx context.Context, query string, limit int) ([]*domain.Product, error) {
+	args := m.Called(query, limit)
+	return args.Get(0).([]*domain.Product), args.Error(1)
+}
+
 // UpdateProduct mocks the UpdateProduct method
-func (m *MockProductRepository) UpdateProduct(product *domain.Product) error {
+func (m *MockProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) error {
 	args := m.Called(product)
 	return args.Error(0)
 }
 
+// SaveProducts mocks the SaveProducts method
+func (m *MockProductRepository) SaveProducts(ctx context.Context, products []*domain.Product) error {
+	args := m.Called(products)
+	return args.Error(0)
+}
+
+// UpdateProducts mocks the UpdateProducts method
+func (m *MockProductRepository) UpdateProducts(ctx context.Context, products []*domain.Product) error {
+	args := m.Called(products)
+	return args.Error(0)
+}
+
+// DeleteProducts mocks the DeleteProducts method
+func (m *MockProductRepository) DeleteProducts(ctx context.Context, items []port.BulkDelete) error {
+	args := m.Called(items)
+	return args.Error(0)
+}
+
+// AdjustStock mocks the AdjustStock method
+func (m *MockProductRepository) AdjustStock(ctx context.Context, productID domain.ProductID, delta int) (*domain.Product, error) {
+	args := m.Called(productID, delta)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Product), args.Error(1)
+}
+
 // DeleteProduct mocks the DeleteProduct method
-func (m *MockProductRepository) DeleteProduct(productID interface{}) error {
+func (m *MockProductRepository) DeleteProduct(ctx context.Context, productID domain.ProductID, version int64) error {
+	args := m.Called(productID, version)
+	return args.Error(0)
+}
+
+// RestoreProduct mocks the RestoreProduct method
+func (m *MockProductRepository) RestoreProduct(ctx context.Context, productID domain.ProductID) error {
+	args := m.Called(productID)
+	return args.Error(0)
+}
+
+// PurgeProduct mocks the PurgeProduct method
+func (m *MockProductRepository) PurgeProduct(ctx context.Context, productID domain.ProductID) error {
 	args := m.Called(productID)
 	return args.Error(0)
 }
@@ -66,6 +132,7 @@ func TestAPI(t *testing.T) {
 	productHandler := http.NewProductHandlers(productService)
 
 	app.Get("/products", productHandler.GetAllProducts)
+	app.Get("/products/search", productHandler.SearchProducts)
 	app.Post("/products", productHandler.CreateProduct)
 	app.Put("/products/:id", productHandler.UpdateProduct)
 	app.Get("/products/:id", productHandler.GetProduct)
@@ -73,12 +140,13 @@ func TestAPI(t *testing.T) {
 
 	t.Run("GET /products", func(t *testing.T) {
 		t.Run("returns a list of products when products exist", func(t *testing.T) {
-			// Mock the GetAllProducts method to return some test products
+			// Mock the ListProducts method to return some test products
 			mockProducts := []*domain.Product{
-				{ID: new(int), ProductName: "Test Product 1", Price: 10.0, Stock: 5},
-				{ID: new(int), ProductName: "Test Product 2", Price: 20.0, Stock: 10},
+				{ID: "1", ProductName: "Test Product 1", Price: 10.0, Stock: 5},
+				{ID: "2", ProductName: "Test Product 2", Price: 20.0, Stock: 10},
 			}
-			mockRepo.On("GetAllProducts").Return(mockProducts, nil)
+			mockRepo.On("ListProducts", port.ProductQuery{Limit: application.DefaultPageSize}).
+				Return(&port.Page{Products: mockProducts}, nil)
 
 			req := httptest.NewRequest(netHTTP.MethodGet, "/products", nil)
 			resp, err := app.Test(req)
@@ -110,8 +178,9 @@ func TestAPI(t *testing.T) {
 			app := fiber.New()
 			app.Get("/products", productHandler.GetAllProducts)
 
-			// Mock the GetAllProducts method to return an empty list
-			mockRepo.On("GetAllProducts").Return([]*domain.Product{}, nil)
+			// Mock the ListProducts method to return an empty page
+			mockRepo.On("ListProducts", port.ProductQuery{Limit: application.DefaultPageSize}).
+				Return(&port.Page{Products: []*domain.Product{}}, nil)
 
 			req := httptest.NewRequest(netHTTP.MethodGet, "/products", nil)
 			resp, err := app.Test(req)
@@ -134,6 +203,62 @@ func TestAPI(t *testing.T) {
 		})
 	})
 
+	t.Run("GET /products with pagination", func(t *testing.T) {
+		t.Run("passes limit and cursor through and returns next_cursor", func(t *testing.T) {
+			cursor := port.EncodeCursor(port.Cursor{AfterID: "2"})
+			nextCursor := port.EncodeCursor(port.Cursor{AfterID: "3"})
+			mockRepo.On("ListProducts", port.ProductQuery{Limit: 1, Cursor: cursor}).
+				Return(&port.Page{
+					Products:   []*domain.Product{{ID: "3", ProductName: "Test Product 3"}},
+					NextCursor: nextCursor,
+				}, nil)
+
+			req := httptest.NewRequest(netHTTP.MethodGet, "/products?limit=1&cursor="+cursor, nil)
+			resp, err := app.Test(req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+
+			var responseBody struct {
+				Data       []domain.Product `json:"data"`
+				NextCursor string           `json:"next_cursor"`
+			}
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&responseBody))
+			assert.Len(t, responseBody.Data, 1)
+			assert.Equal(t, nextCursor, responseBody.NextCursor)
+		})
+
+		t.Run("rejects a malformed limit", func(t *testing.T) {
+			req := httptest.NewRequest(netHTTP.MethodGet, "/products?limit=many", nil)
+			resp, err := app.Test(req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, netHTTP.StatusBadRequest, resp.StatusCode)
+		})
+	})
+
+	t.Run("GET /products/search", func(t *testing.T) {
+		t.Run("returns ranked matches", func(t *testing.T) {
+			mockRepo.On("SearchProducts", "blue pen", application.DefaultPageSize).
+				Return([]*domain.Product{{ID: "1", ProductName: "Blue Pen"}}, nil)
+
+			req := httptest.NewRequest(netHTTP.MethodGet, "/products/search?q=blue+pen", nil)
+			resp, err := app.Test(req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+			mockRepo.AssertExpectations(t)
+		})
+
+		t.Run("requires a query", func(t *testing.T) {
+			req := httptest.NewRequest(netHTTP.MethodGet, "/products/search", nil)
+			resp, err := app.Test(req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, netHTTP.StatusBadRequest, resp.StatusCode)
+		})
+	})
+
 	t.Run("POST /products", func(t *testing.T) {
 		t.Run("creates a new product", func(t *testing.T) {
 			newProduct := domain.Product{
@@ -171,7 +296,7 @@ func TestAPI(t *testing.T) {
 			resp, err := app.Test(req)
 
 			assert.NoError(t, err)
-			assert.Equal(t, netHTTP.StatusInternalServerError, resp.StatusCode) // Or your expected error code
+			assert.Equal(t, netHTTP.StatusBadRequest, resp.StatusCode)
 
 			// Assert the response body contains an error message
 			// ...
@@ -181,12 +306,11 @@ func TestAPI(t *testing.T) {
 	t.Run("PUT /products/:id", func(t *testing.T) {
 		t.Run("updates an existing product", func(t *testing.T) {
 			existingProduct := &domain.Product{
-				ID:          new(int), // Assign a dummy ID
+				ID:          "1",
 				ProductName: "Existing Product",
 				Price:       10.0,
 				Stock:       5,
 			}
-			existingProduct.ID = 1 // Set the ID to 1 for testing
 
 			updatedProduct := domain.Product{
 				ID:          existingProduct.ID,
@@ -197,7 +321,7 @@ func TestAPI(t *testing.T) {
 			requestBody, _ := json.Marshal(updatedProduct)
 
 			// Expect FindProductByID to be called and return the existing product
-			mockRepo.On("FindProductByID", 1).Return(existingProduct, nil)
+			mockRepo.On("FindProductByID", domain.ProductID("1")).Return(existingProduct, nil)
 			// Expect UpdateProduct to be called and return no error
 			mockRepo.On("UpdateProduct", mock.AnythingOfType("*domain.Product")).Return(nil)
 
@@ -214,7 +338,7 @@ func TestAPI(t *testing.T) {
 		})
 
 		t.Run("returns an error if product is not found", func(t *testing.T) {
-			mockRepo.On("FindProductByID", 2).Return(nil, fmt.Errorf("product not found")) // Simulate product not found
+			mockRepo.On("FindProductByID", domain.ProductID("2")).Return(nil, domain.ErrNotFound) // Simulate product not found
 
 			req := httptest.NewRequest(netHTTP.MethodPut, "/products/2", nil) // No need for request body in this case
 			req.Header.Set("Content-Type", "application/json")
@@ -230,13 +354,12 @@ func TestAPI(t *testing.T) {
 	t.Run("GET /products/:id", func(t *testing.T) {
 		t.Run("returns a product by ID", func(t *testing.T) {
 			mockProduct := &domain.Product{
-				ID:          new(int),
+				ID:          "1",
 				ProductName: "Test Product",
 				Price:       10.0,
 				Stock:       5,
 			}
-			mockProduct.ID = 1
-			mockRepo.On("FindProductByID", 1).Return(mockProduct, nil)
+			mockRepo.On("FindProductByID", domain.ProductID("1")).Return(mockProduct, nil)
 
 			req := httptest.NewRequest(netHTTP.MethodGet, "/products/1", nil)
 			resp, err := app.Test(req)
@@ -254,20 +377,14 @@ func TestAPI(t *testing.T) {
 			assert.NoError(t, err)
 
 			assert.NotNil(t, responseBody.Data)
-			mockID, ok := mockProduct.ID.(int)
-			assert.True(t, ok, "mockProduct.ID is not of type int")
-
-			responseID, ok := responseBody.Data.ID.(float64)
-			assert.True(t, ok, "responseBody.Data.ID is not of type float64")
-
-			assert.Equal(t, float64(mockID), responseID)
+			assert.Equal(t, mockProduct.ID, responseBody.Data.ID)
 			// ... add more assertions to check other fields if needed ...
 
 			mockRepo.AssertExpectations(t)
 		})
 
 		t.Run("returns an error if product is not found", func(t *testing.T) {
-			mockRepo.On("FindProductByID", 2).Return(nil, fmt.Errorf("product not found"))
+			mockRepo.On("FindProductByID", domain.ProductID("2")).Return(nil, domain.ErrNotFound)
 
 			req := httptest.NewRequest(netHTTP.MethodGet, "/products/2", nil)
 			resp, err := app.Test(req)
@@ -282,7 +399,7 @@ func TestAPI(t *testing.T) {
 	t.Run("DELETE /products/:id", func(t *testing.T) {
 		t.Run("deletes a product by ID", func(t *testing.T) {
 			// Expect DeleteProduct to be called and return no error
-			mockRepo.On("DeleteProduct", 1).Return(nil)
+			mockRepo.On("DeleteProduct", domain.ProductID("1"), int64(0)).Return(nil)
 
 			req := httptest.NewRequest(netHTTP.MethodDelete, "/products/1", nil)
 			resp, err := app.Test(req)
@@ -296,15 +413,143 @@ func TestAPI(t *testing.T) {
 		})
 
 		t.Run("returns an error if product is not found", func(t *testing.T) {
-			mockRepo.On("DeleteProduct", 2).Return(fmt.Errorf("product not found"))
+			mockRepo.On("DeleteProduct", domain.ProductID("2"), int64(0)).Return(domain.ErrNotFound)
 
 			req := httptest.NewRequest(netHTTP.MethodDelete, "/products/2", nil)
 			resp, err := app.Test(req)
 
 			assert.NoError(t, err)
 			assert.Equal(t, netHTTP.StatusNotFound, resp.StatusCode) // Or your expected error code
+			// Without an audit trail the delete goes straight to the repository
+			mockRepo.AssertCalled(t, "DeleteProduct", domain.ProductID("2"), int64(0))
 
 			// ... assert the response body contains an error message ...
 		})
 	})
+
+	t.Run("ETag and If-Match", func(t *testing.T) {
+		newApp := func(current *domain.Product) (*fiber.App, *MockProductRepository) {
+			mockRepo := new(MockProductRepository)
+			mockRepo.On("FindProductByID", current.ID).Return(current, nil)
+
+			productHandler := http.NewProductHandlers(application.NewProductService(mockRepo))
+			app := fiber.New()
+			app.Get("/products/:id", productHandler.GetProduct)
+			app.Put("/products/:id", productHandler.UpdateProduct)
+			app.Delete("/products/:id", productHandler.DeleteProduct)
+			return app, mockRepo
+		}
+		send := func(app *fiber.App, method, ifMatch string) *netHTTP.Response {
+			body, _ := json.Marshal(domain.Product{ProductName: "Renamed", Price: 3, Stock: 1, Version: 99})
+			req := httptest.NewRequest(method, "/products/7", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			if ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			return resp
+		}
+
+		t.Run("tags a product with its version", func(t *testing.T) {
+			app, _ := newApp(&domain.Product{ID: "7", ProductName: "Lamp", Version: 4})
+
+			resp := send(app, netHTTP.MethodGet, "")
+			assert.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+			assert.Equal(t, `"4"`, resp.Header.Get("ETag"))
+		})
+
+		t.Run("updates the tagged version and returns the new ETag", func(t *testing.T) {
+			app, mockRepo := newApp(&domain.Product{ID: "7", ProductName: "Lamp", Version: 4})
+			mockRepo.On("UpdateProduct", mock.MatchedBy(func(product *domain.Product) bool {
+				return product.Version == 4
+			})).Run(func(args mock.Arguments) {
+				args.Get(0).(*domain.Product).Version = 5
+			}).Return(nil)
+
+			resp := send(app, netHTTP.MethodPut, `W/"3", "4"`)
+			assert.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+			assert.Equal(t, `"5"`, resp.Header.Get("ETag"))
+			mockRepo.AssertExpectations(t)
+		})
+
+		t.Run("rejects a stale tag with 412", func(t *testing.T) {
+			app, mockRepo := newApp(&domain.Product{ID: "7", ProductName: "Lamp", Version: 4})
+
+			assert.Equal(t, netHTTP.StatusPreconditionFailed, send(app, netHTTP.MethodPut, `"3"`).StatusCode)
+			assert.Equal(t, netHTTP.StatusPreconditionFailed, send(app, netHTTP.MethodDelete, `"3"`).StatusCode)
+			mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything)
+			mockRepo.AssertNotCalled(t, "DeleteProduct", mock.Anything, mock.Anything)
+		})
+
+		t.Run("reports a lost race as 412 with If-Match and 409 without", func(t *testing.T) {
+			app, mockRepo := newApp(&domain.Product{ID: "7", ProductName: "Lamp", Version: 4})
+			mockRepo.On("UpdateProduct", mock.AnythingOfType("*domain.Product")).Return(domain.ErrVersionMismatch)
+
+			assert.Equal(t, netHTTP.StatusPreconditionFailed, send(app, netHTTP.MethodPut, "*").StatusCode)
+			assert.Equal(t, netHTTP.StatusConflict, send(app, netHTTP.MethodPut, "").StatusCode)
+		})
+
+		t.Run("deletes the tagged version", func(t *testing.T) {
+			app, mockRepo := newApp(&domain.Product{ID: "7", ProductName: "Lamp", Version: 4})
+			mockRepo.On("DeleteProduct", domain.ProductID("7"), int64(4)).Return(nil)
+
+			assert.Equal(t, netHTTP.StatusOK, send(app, netHTTP.MethodDelete, `"4"`).StatusCode)
+			mockRepo.AssertExpectations(t)
+		})
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		mockRepo := new(MockProductRepository)
+		productHandler := http.NewProductHandlers(application.NewProductService(mockRepo))
+
+		app := fiber.New()
+		app.Get("/products/trash", productHandler.ListTrash)
+		app.Get("/products/:id", productHandler.GetProduct)
+		app.Post("/products/:id/restore", productHandler.RestoreProduct)
+		app.Delete("/products/:id/purge", productHandler.PurgeProduct)
+
+		t.Run("lists deleted products", func(t *testing.T) {
+			query := port.ProductQuery{
+				Filter: port.ProductFilter{Deleted: true},
+				Limit:  application.DefaultPageSize,
+			}
+			mockRepo.On("ListProducts", query).
+				Return(&port.Page{Products: []*domain.Product{{ID: "4", ProductName: "Old"}}}, nil)
+
+			resp, err := app.Test(httptest.NewRequest(netHTTP.MethodGet, "/products/trash", nil))
+			assert.NoError(t, err)
+			assert.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+			mockRepo.AssertExpectations(t)
+		})
+
+		t.Run("restores a product", func(t *testing.T) {
+			mockRepo.On("RestoreProduct", domain.ProductID("4")).Return(nil)
+			mockRepo.On("FindProductByID", domain.ProductID("4")).
+				Return(&domain.Product{ID: "4", ProductName: "Old", Version: 3}, nil)
+
+			resp, err := app.Test(httptest.NewRequest(netHTTP.MethodPost, "/products/4/restore", nil))
+			assert.NoError(t, err)
+			assert.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+			assert.Equal(t, `"3"`, resp.Header.Get("ETag"))
+			mockRepo.AssertExpectations(t)
+		})
+
+		t.Run("returns 404 when restoring a product not in the trash", func(t *testing.T) {
+			mockRepo.On("RestoreProduct", domain.ProductID("5")).Return(domain.ErrNotFound)
+
+			resp, err := app.Test(httptest.NewRequest(netHTTP.MethodPost, "/products/5/restore", nil))
+			assert.NoError(t, err)
+			assert.Equal(t, netHTTP.StatusNotFound, resp.StatusCode)
+		})
+
+		t.Run("purges a product", func(t *testing.T) {
+			mockRepo.On("PurgeProduct", domain.ProductID("4")).Return(nil)
+
+			resp, err := app.Test(httptest.NewRequest(netHTTP.MethodDelete, "/products/4/purge", nil))
+			assert.NoError(t, err)
+			assert.Equal(t, netHTTP.StatusOK, resp.StatusCode)
+			mockRepo.AssertExpectations(t)
+		})
+	})
 }